@@ -2,16 +2,53 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
 
+	"github.com/Pertsaa/go-radio/internal/config"
 	"github.com/Pertsaa/go-radio/internal/handler"
+	"github.com/Pertsaa/go-radio/internal/logging"
 	"github.com/Pertsaa/go-radio/internal/middleware"
 )
 
 func main() {
+	overrides := config.Overrides{}
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to a YAML, TOML or JSON config file")
+	overrides.Flag(flag.CommandLine, "addr", "app.addr", "address to listen on")
+	overrides.Flag(flag.CommandLine, "log-format", "log.format", "log output format (text or json)")
+	overrides.Flag(flag.CommandLine, "log-level", "log.level", "minimum log level (debug, info, warn, error)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath, overrides)
+	if err != nil {
+		fmt.Println("Invalid configuration:", err)
+		os.Exit(1)
+	}
+
+	logLevel := new(slog.LevelVar)
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logLevel.Set(level)
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, logLevel)
+	if err != nil {
+		fmt.Println("Invalid logging configuration:", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
 	ctx := context.Background()
 
+	cors := middleware.NewCORSPolicy(cfg.App.CORS.Options(), nil)
+
+	config.OnReload(ctx, *configPath, overrides, func(next *config.Config) {
+		level, _ := logging.ParseLevel(next.Log.Level)
+		logLevel.Set(level)
+		cors.Set(next.App.CORS.Options(), nil)
+	})
+
 	r := http.NewServeMux()
 
 	h := handler.NewAppHandler(ctx)
@@ -21,17 +58,18 @@ func main() {
 	r.HandleFunc("GET /favicon.png", handler.Make(h.FaviconHandler))
 
 	stack := middleware.CreateStack(
+		middleware.RequestID,
 		middleware.Log,
-		middleware.CORS,
+		cors.Middleware,
 	)
 
 	server := http.Server{
-		Addr:    ":3000",
+		Addr:    cfg.App.Addr,
 		Handler: stack(r),
 	}
 
-	fmt.Println("Server listening on port 3000...")
+	slog.Info("server listening", "addr", server.Addr)
 	if err := server.ListenAndServe(); err != nil {
-		fmt.Println("Error starting server:", err)
+		slog.Error("error starting server", "error", err)
 	}
 }
@@ -7,7 +7,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
@@ -16,13 +16,16 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/Pertsaa/go-radio/internal/config"
+	"github.com/Pertsaa/go-radio/internal/logging"
 	"github.com/bwmarrin/discordgo"
 	"layeh.com/gopus"
 )
 
 var (
-	radioBaseURL = "http://localhost:8080"
-	token        string
+	radioBaseURL string
+	configPath   string
+	overrides    = config.Overrides{}
 	vcs          = make(map[string]*discordgo.VoiceConnection)
 	vcMu         sync.Mutex
 	ffmpegs      = make(map[string]context.CancelFunc)
@@ -48,19 +51,49 @@ var commands = []*discordgo.ApplicationCommand{
 }
 
 func init() {
-	flag.StringVar(&token, "t", "", "Bot Token")
+	flag.StringVar(&configPath, "config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to a YAML, TOML or JSON config file")
+	overrides.Flag(flag.CommandLine, "t", "discord.token", "Bot Token")
+	overrides.Flag(flag.CommandLine, "radio-url", "discord.radio_base_url", "base URL of the radio server")
+	overrides.Flag(flag.CommandLine, "log-format", "log.format", "log output format (text or json)")
+	overrides.Flag(flag.CommandLine, "log-level", "log.level", "minimum log level (debug, info, warn, error)")
 	flag.Parse()
 }
 
 func main() {
-	if token == "" {
+	cfg, err := config.Load(configPath, overrides)
+	if err != nil {
+		fmt.Println("Invalid configuration:", err)
+		return
+	}
+
+	if cfg.Discord.Token == "" {
 		fmt.Println("No token provided. Please run: bot -t <bot token>")
 		return
 	}
 
-	dg, err := discordgo.New("Bot " + token)
+	radioBaseURL = cfg.Discord.RadioBaseURL
+
+	logLevel := new(slog.LevelVar)
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logLevel.Set(level)
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, logLevel)
 	if err != nil {
-		fmt.Println("Error creating Discord session: ", err)
+		fmt.Println("Invalid logging configuration:", err)
+		return
+	}
+	slog.SetDefault(logger)
+
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	config.OnReload(reloadCtx, configPath, overrides, func(next *config.Config) {
+		level, _ := logging.ParseLevel(next.Log.Level)
+		logLevel.Set(level)
+	})
+
+	dg, err := discordgo.New("Bot " + cfg.Discord.Token)
+	if err != nil {
+		slog.Error("error creating Discord session", "error", err)
 		return
 	}
 
@@ -72,18 +105,18 @@ func main() {
 	dg.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildVoiceStates
 
 	if err := dg.Open(); err != nil {
-		fmt.Println("Error opening Discord session: ", err)
+		slog.Error("error opening Discord session", "error", err)
 		return
 	}
 
 	for _, v := range commands {
 		_, err := dg.ApplicationCommandCreate(dg.State.User.ID, "", v)
 		if err != nil {
-			log.Println("Cannot create slash command:", err)
+			slog.Error("cannot create slash command", "command", v.Name, "error", err)
 		}
 	}
 
-	fmt.Println("Radio bot is running. Press CTRL-C to exit.")
+	slog.Info("radio bot is running, press CTRL-C to exit")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
@@ -131,9 +164,10 @@ func radioInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 	joinUserVoiceChannel(s, i)
 
-	go streamChannel(i.GuildID, channels[0])
+	channel := defaultChannel(channels)
+	go streamChannel(i.GuildID, channel)
 
-	respondMessage(s, i, fmt.Sprintf("Streaming %s...", channels[0].Name))
+	respondMessage(s, i, fmt.Sprintf("Streaming %s...", channel.DisplayName))
 }
 
 // Switch stream to different radio channel
@@ -148,7 +182,7 @@ func channelInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	var channel Channel
 	found := false
 	for _, ch := range channels {
-		if strings.EqualFold(ch.Name, channelName) {
+		if strings.EqualFold(ch.Name, channelName) || strings.EqualFold(ch.DisplayName, channelName) {
 			channel = ch
 			found = true
 			break
@@ -169,7 +203,7 @@ func channelInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 	go streamChannel(i.GuildID, channel)
 
-	respondMessage(s, i, fmt.Sprintf("Streaming %s...", channelName))
+	respondMessage(s, i, fmt.Sprintf("Streaming %s...", channel.DisplayName))
 }
 
 // Fetch and list available radio channels
@@ -182,7 +216,11 @@ func channelListInteraction(s *discordgo.Session, i *discordgo.InteractionCreate
 
 	var names []string
 	for _, ch := range channels {
-		names = append(names, ch.Name)
+		if ch.DisplayName != ch.Name {
+			names = append(names, fmt.Sprintf("%s (%s)", ch.DisplayName, ch.Name))
+		} else {
+			names = append(names, ch.Name)
+		}
 	}
 
 	respondMessage(s, i, "Available channels: "+strings.Join(names, ", "))
@@ -240,7 +278,7 @@ func voiceStateUpdate(s *discordgo.Session, vs *discordgo.VoiceStateUpdate) {
 			vc.Disconnect()
 			delete(vcs, guildID)
 		}
-		log.Println("Disconnected from empty voice channel in guild:", guildID)
+		slog.Info("disconnected from empty voice channel", "guild", guildID)
 	}
 }
 
@@ -309,7 +347,7 @@ func streamChannel(guildID string, channel Channel) error {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				return nil
 			}
-			log.Println("Error reading PCM from FFmpeg:", err)
+			slog.Error("error reading PCM from FFmpeg", "guild", guildID, "channel", channel.Name, "error", err)
 			return err
 		}
 
@@ -328,7 +366,7 @@ func streamChannel(guildID string, channel Channel) error {
 
 		opusFrame, err := encoder.Encode(pcm, frameSize, 4000)
 		if err != nil {
-			log.Println("Opus encode error:", err)
+			slog.Warn("opus encode error", "guild", guildID, "channel", channel.Name, "error", err)
 			continue
 		}
 		vc.OpusSend <- opusFrame
@@ -403,8 +441,20 @@ func cleanup() {
 }
 
 type Channel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Default     bool   `json:"default"`
+}
+
+// defaultChannel returns the channel marked as default, or the first one.
+func defaultChannel(channels []Channel) Channel {
+	for _, ch := range channels {
+		if ch.Default {
+			return ch
+		}
+	}
+	return channels[0]
 }
 
 func fetchChannels(baseURL string) ([]Channel, error) {
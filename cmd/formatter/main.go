@@ -1,24 +1,38 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/Pertsaa/go-radio/internal/config"
 )
 
 // main is the entry point of the program.
 func main() {
+	overrides := config.Overrides{}
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to a YAML, TOML or JSON config file")
+	overrides.Flag(flag.CommandLine, "bitrate", "formatter.bitrate", "audio bitrate passed to ffmpeg, e.g. 192k")
+	flag.Parse()
+
 	// Check for the correct number of command-line arguments.
-	if len(os.Args) != 3 {
-		fmt.Printf("Usage: %s <input_directory> <output_directory>\n", os.Args[0])
+	if flag.NArg() != 2 {
+		fmt.Printf("Usage: %s [flags] <input_directory> <output_directory>\n", os.Args[0])
 		os.Exit(1)
 	}
 
-	inputDir := os.Args[1]
-	outputDir := os.Args[2]
+	// Load the configuration for the output bitrate.
+	cfg, err := config.Load(*configPath, overrides)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	inputDir := flag.Arg(0)
+	outputDir := flag.Arg(1)
 
 	// Ensure the output directory exists. If not, create it.
 	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
@@ -27,6 +41,7 @@ func main() {
 
 	fmt.Printf("Scanning directory: %s\n", inputDir)
 	fmt.Printf("Output directory set to: %s\n", outputDir)
+	fmt.Printf("Bitrate set to: %s\n", cfg.Formatter.Bitrate)
 	fmt.Println("----------------------------------------")
 
 	// Read all directory entries from the input directory.
@@ -49,7 +64,7 @@ func main() {
 		fmt.Printf("Processing file: %s -> %s\n", inputPath, outputPath)
 
 		// Create the FFmpeg command.
-		cmd := exec.Command("ffmpeg", "-i", inputPath, "-b:a", "192k", "-y", outputPath)
+		cmd := exec.Command("ffmpeg", "-i", inputPath, "-b:a", cfg.Formatter.Bitrate, "-y", outputPath)
 
 		// Run the command and capture any errors.
 		output, err := cmd.CombinedOutput()
@@ -2,53 +2,272 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"syscall"
 
+	"github.com/Pertsaa/go-radio/internal/config"
 	"github.com/Pertsaa/go-radio/internal/handler"
+	"github.com/Pertsaa/go-radio/internal/logging"
 	"github.com/Pertsaa/go-radio/internal/middleware"
 	"github.com/Pertsaa/go-radio/internal/radio"
+	"github.com/Pertsaa/go-radio/internal/store"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("usage: ./go-radio <data_dir>")
+	overrides := config.Overrides{}
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to a YAML, TOML or JSON config file")
+	overrides.Flag(flag.CommandLine, "addr", "radio.addr", "address to listen on")
+	overrides.Flag(flag.CommandLine, "max-listeners", "radio.limits.max_listeners", "maximum concurrent listeners across all channels (0 = unlimited)")
+	overrides.Flag(flag.CommandLine, "max-channel-listeners", "radio.limits.max_channel_listeners", "maximum concurrent listeners per channel (0 = unlimited)")
+	overrides.Flag(flag.CommandLine, "max-ip-listeners", "radio.limits.max_listeners_per_ip", "maximum concurrent listeners per client IP (0 = unlimited)")
+	overrides.Flag(flag.CommandLine, "admin-key", "radio.admin_key", "key that lets admin clients bypass listener limits")
+	overrides.Flag(flag.CommandLine, "log-format", "log.format", "log output format (text or json)")
+	overrides.Flag(flag.CommandLine, "log-level", "log.level", "minimum log level (debug, info, warn, error)")
+	overrides.Flag(flag.CommandLine, "shutdown-timeout", "radio.shutdown_timeout", "time to wait for connections to drain on shutdown")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		overrides["radio.data_dir"] = flag.Arg(0)
+	}
+
+	cfg, err := config.Load(*configPath, overrides)
+	if err != nil {
+		fmt.Println("Invalid configuration:", err)
+		os.Exit(1)
+	}
+
+	if cfg.Radio.DataDir == "" {
+		fmt.Println("usage: ./go-radio [flags] <data_dir>")
 		os.Exit(1)
 	}
 
-	dataDir := os.Args[1]
+	logLevel := new(slog.LevelVar)
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logLevel.Set(level)
 
-	goRadio := radio.New(dataDir)
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, logLevel)
+	if err != nil {
+		fmt.Println("Invalid logging configuration:", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
 
-	err := goRadio.LoadChannels()
+	storePath := cfg.Radio.StorePath
+	if storePath == "" {
+		storePath = filepath.Join(cfg.Radio.DataDir, store.FileName)
+	}
+	db, err := store.Open(storePath)
 	if err != nil {
-		log.Fatalf("Server failed to load channels: %v", err)
+		slog.Error("server failed to open store", "error", err)
+		os.Exit(1)
 	}
+	defer db.Close()
 
-	go goRadio.Broadcast()
+	goRadio := radio.New(cfg.Radio.DataDir)
+	goRadio.SetSettings(radioSettings(cfg))
+	goRadio.SetLimits(radioLimits(cfg))
+	goRadio.SetStore(db)
+	goRadio.SetRecordingOptions(recordingOptions(cfg))
+	goRadio.SetLibraryScanInterval(cfg.Radio.LibraryScan.Duration)
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go db.RunHistoryRetention(ctx, cfg.Radio.HistoryRetention.Duration)
+
+	err = goRadio.LoadChannels(ctx)
+	if err != nil {
+		slog.Error("server failed to load channels", "error", err)
+		os.Exit(1)
+	}
+
+	broadcastDone := make(chan struct{})
+	go func() {
+		goRadio.Broadcast(ctx)
+		close(broadcastDone)
+	}()
 
 	r := http.NewServeMux()
 
-	h := handler.NewAPIHandler(ctx, goRadio)
+	httpMetrics := middleware.NewHTTPMetrics()
+
+	h := handler.NewAPIHandler(ctx, goRadio, cfg.Radio.AdminKey, httpMetrics)
+	h.SetPublicURL(cfg.Radio.PublicURL)
+
+	cors := middleware.NewCORSPolicy(cfg.Radio.CORS.Options(), cfg.Radio.CORSRouteOptions())
 
 	r.HandleFunc("GET /radio/channels", handler.Make(h.RadioChannelListHandler))
 	r.HandleFunc("GET /radio/channels/{channelID}/stream", handler.Make(h.RadioChannelStreamHandler))
+	r.HandleFunc("GET /radio/stats", handler.Make(h.RadioStatsHandler))
+	r.HandleFunc("GET /radio/channels/{channelID}/stats", handler.Make(h.RadioChannelStatsHandler))
+	r.HandleFunc("GET /radio/channels/{channelID}/history", handler.Make(h.RadioChannelHistoryHandler))
+	r.HandleFunc("GET /radio/channels/{channelID}/now-playing", handler.Make(h.RadioChannelNowPlayingHandler))
+	r.HandleFunc("GET /radio/artwork/{hash}", handler.Make(h.RadioArtworkHandler))
+	r.HandleFunc("GET /radio/library", handler.Make(h.RadioLibraryHandler))
+	r.HandleFunc("GET /radio/channels/{channelID}/feed.xml", handler.Make(h.RadioChannelFeedHandler))
+	r.HandleFunc("GET /radio/channels/{channelID}/artwork", handler.Make(h.RadioChannelArtworkHandler))
+	r.HandleFunc("POST /radio/channels/{channelID}/recording", handler.Make(h.RadioRecordingStartHandler))
+	r.HandleFunc("DELETE /radio/channels/{channelID}/recording", handler.Make(h.RadioRecordingStopHandler))
+	r.HandleFunc("GET /radio/recordings", handler.Make(h.RadioRecordingListHandler))
+	r.HandleFunc("GET /radio/recordings/{recordingID}", handler.Make(h.RadioRecordingHandler))
+	r.HandleFunc("GET /radio/recordings/{recordingID}/cues", handler.Make(h.RadioRecordingCuesHandler))
+	r.HandleFunc("DELETE /radio/listeners/{listenerID}", handler.Make(h.RadioListenerKickHandler))
+	r.HandleFunc("GET /metrics", handler.Make(h.MetricsHandler))
+	r.HandleFunc("GET /healthz", handler.Make(h.HealthHandler))
+	r.HandleFunc("GET /readyz", handler.Make(h.ReadyHandler))
 
 	stack := middleware.CreateStack(
-		middleware.CORS,
+		middleware.RequestID,
+		middleware.Log,
+		httpMetrics.Middleware,
+		cors.Middleware,
 	)
 
 	server := http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Radio.Addr,
 		Handler: stack(r),
 	}
 
-	fmt.Println("Server listening on port 8080...")
-	if err := server.ListenAndServe(); err != nil {
-		fmt.Println("Error starting server:", err)
+	go func() {
+		slog.Info("server listening", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("error starting server", "error", err)
+			stop()
+		}
+	}()
+
+	var current atomic.Pointer[config.Config]
+	current.Store(cfg)
+
+	config.OnReload(ctx, *configPath, overrides, func(next *config.Config) {
+		reload(current.Load(), next, logLevel, goRadio, h, cors)
+		current.Store(next)
+	})
+
+	<-ctx.Done()
+	stop()
+
+	shutdownTimeout := current.Load().Radio.ShutdownTimeout.Duration
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error shutting down server", "error", err)
+	}
+
+	select {
+	case <-broadcastDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("timed out waiting for broadcasters to stop")
+	}
+
+	slog.Info("server stopped")
+}
+
+func radioSettings(cfg *config.Config) (radio.Settings, map[string]radio.ChannelSettings) {
+	defaults := radio.Settings{
+		BufferLength: cfg.Radio.BufferLength,
+		ChunkSize:    cfg.Radio.ChunkSize,
+		TickInterval: cfg.Radio.TickInterval.Duration,
+		Mode:         radio.PlaybackMode(cfg.Radio.Mode),
+		Epoch:        cfg.Radio.Epoch,
+		IdleAfter:    cfg.Radio.IdleAfter.Duration,
+		WriteTimeout: cfg.Radio.WriteTimeout.Duration,
+		QueueSize:    cfg.Radio.QueueSize,
+		QueuePolicy:  radio.QueuePolicy(cfg.Radio.QueuePolicy),
+		MaxLag:       cfg.Radio.MaxLag.Duration,
+		TimeShift:    cfg.Radio.TimeShift.Duration,
+	}
+
+	channels := make(map[string]radio.ChannelSettings, len(cfg.Radio.Channels))
+	for name, ch := range cfg.Radio.Channels {
+		settings := radio.ChannelSettings{
+			BufferLength: ch.BufferLength,
+			ChunkSize:    ch.ChunkSize,
+			TickInterval: ch.TickInterval.Duration,
+			Mode:         radio.PlaybackMode(ch.Mode),
+			Epoch:        ch.Epoch,
+			WriteTimeout: ch.WriteTimeout.Duration,
+			QueueSize:    ch.QueueSize,
+			QueuePolicy:  radio.QueuePolicy(ch.QueuePolicy),
+			MaxLag:       ch.MaxLag.Duration,
+			MaxListeners: ch.MaxListeners,
+		}
+		if ch.IdleAfter != nil {
+			settings.IdleAfter = &ch.IdleAfter.Duration
+		}
+		if ch.TimeShift != nil {
+			settings.TimeShift = &ch.TimeShift.Duration
+		}
+		channels[name] = settings
+	}
+
+	return defaults, channels
+}
+
+func recordingOptions(cfg *config.Config) radio.RecordingOptions {
+	rc := cfg.Radio.Recordings
+	opts := radio.RecordingOptions{
+		Dir:             rc.Dir,
+		Rotate:          rc.Rotate.Duration,
+		MaxAge:          rc.MaxAge.Duration,
+		MaxSize:         int64(rc.MaxSizeMB) * 1024 * 1024,
+		FeedMinDuration: rc.FeedMinDuration.Duration,
+		Continuous:      rc.Continuous,
+	}
+	for _, s := range rc.Schedule {
+		weekday, _ := s.ParseWeekday()
+		opts.Schedule = append(opts.Schedule, radio.RecordingSchedule{
+			Channel:  s.Channel,
+			Weekday:  weekday,
+			Start:    s.Start,
+			Duration: s.Duration.Duration,
+		})
+	}
+	return opts
+}
+
+func radioLimits(cfg *config.Config) radio.Limits {
+	return radio.Limits{
+		MaxListeners:        cfg.Radio.Limits.MaxListeners,
+		MaxChannelListeners: cfg.Radio.Limits.MaxChannelListeners,
+		MaxListenersPerAddr: cfg.Radio.Limits.MaxListenersPerIP,
+	}
+}
+
+// reload applies the settings that are safe to change while running and
+// warns about the ones that need a restart.
+func reload(prev, next *config.Config, logLevel *slog.LevelVar, goRadio *radio.Radio, h *handler.APIHandler, cors *middleware.CORSPolicy) {
+	level, _ := logging.ParseLevel(next.Log.Level)
+	logLevel.Set(level)
+	goRadio.SetLimits(radioLimits(next))
+	h.SetAdminKey(next.Radio.AdminKey)
+	h.SetPublicURL(next.Radio.PublicURL)
+	cors.Set(next.Radio.CORS.Options(), next.Radio.CORSRouteOptions())
+
+	prevDefaults, prevChannels := radioSettings(prev)
+	nextDefaults, nextChannels := radioSettings(next)
+
+	if prev.Radio.Addr != next.Radio.Addr ||
+		prev.Radio.DataDir != next.Radio.DataDir ||
+		prev.Radio.StorePath != next.Radio.StorePath ||
+		prev.Radio.HistoryRetention != next.Radio.HistoryRetention ||
+		prev.Radio.LibraryScan != next.Radio.LibraryScan ||
+		!reflect.DeepEqual(prev.Radio.Recordings, next.Radio.Recordings) ||
+		prev.Log.Format != next.Log.Format ||
+		prevDefaults != nextDefaults ||
+		!reflect.DeepEqual(prevChannels, nextChannels) {
+		slog.Warn("some configuration changes require a restart to take effect")
 	}
 }
@@ -0,0 +1,194 @@
+// Package artwork resizes cover images and caches the results on disk, keyed
+// by the hash of the original image.
+package artwork
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// Sizes are the thumbnail edge lengths, in pixels, that images are resized
+// to. Other requested sizes are rounded up to the next one.
+var Sizes = []int{64, 128, 256, 512, 1024}
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrNotFound          = errors.New("image not found")
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Image is an image ready to be served.
+type Image struct {
+	Path        string
+	ContentType string
+	// Hash identifies the original image; it is the same for every size.
+	Hash string
+	// Size is the longest edge the image was scaled to fit, or 0 for the
+	// original.
+	Size int
+}
+
+// ETag returns a strong entity tag for the image.
+func (img Image) ETag() string {
+	return `"` + img.Hash + "-" + strconv.Itoa(img.Size) + `"`
+}
+
+// Cache stores resized images in a directory.
+type Cache struct {
+	dir string
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Hash returns the cache key of an original image.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// FitSize rounds size up to one of Sizes. Zero, and anything larger than the
+// biggest thumbnail, selects the original image.
+func FitSize(size int) int {
+	if size <= 0 {
+		return 0
+	}
+	i, _ := slices.BinarySearch(Sizes, size)
+	if i == len(Sizes) {
+		return 0
+	}
+	return Sizes[i]
+}
+
+// ContentType detects the type of a JPEG or PNG image.
+func ContentType(data []byte) (string, error) {
+	switch ct := http.DetectContentType(data); ct {
+	case "image/jpeg", "image/png":
+		return ct, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Thumbnail returns data scaled to fit within size pixels, which must be one
+// of Sizes, or the original when size is 0. Thumbnails are written to the
+// cache on first use. Images are never scaled up.
+func (c *Cache) Thumbnail(data []byte, size int) (Image, error) {
+	contentType, err := ContentType(data)
+	if err != nil {
+		return Image{}, err
+	}
+
+	img := Image{ContentType: contentType, Hash: Hash(data), Size: size}
+	ext := ".jpg"
+	if contentType == "image/png" {
+		ext = ".png"
+	}
+	img.Path = filepath.Join(c.dir, img.Hash+"-"+strconv.Itoa(size)+ext)
+
+	if _, err := os.Stat(img.Path); err == nil {
+		return img, nil
+	}
+
+	out := data
+	if size > 0 {
+		out, err = resize(data, contentType, size)
+		if err != nil {
+			return Image{}, err
+		}
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return Image{}, fmt.Errorf("failed to create artwork cache: %w", err)
+	}
+	// Write through a temporary file so concurrent requests never serve a
+	// partial image.
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return Image{}, err
+	}
+	_, err = tmp.Write(out)
+	if err = errors.Join(err, tmp.Close()); err == nil {
+		err = os.Rename(tmp.Name(), img.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return Image{}, err
+	}
+
+	return img, nil
+}
+
+// Prepare writes the original of data to the cache, so Get can find it by
+// hash, and returns it. Thumbnails are left to Thumbnail, which resizes them
+// on first request, so loading a library does not wait on every size.
+func (c *Cache) Prepare(data []byte) (Image, error) {
+	return c.Thumbnail(data, 0)
+}
+
+// Get returns a thumbnail of an image that was cached before, by the hash of
+// the original.
+func (c *Cache) Get(hash string, size int) (Image, error) {
+	if !hashPattern.MatchString(hash) {
+		return Image{}, ErrNotFound
+	}
+	for _, ext := range []string{".jpg", ".png"} {
+		data, err := os.ReadFile(filepath.Join(c.dir, hash+"-0"+ext))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Image{}, err
+		}
+		return c.Thumbnail(data, size)
+	}
+	return Image{}, ErrNotFound
+}
+
+func resize(data []byte, contentType string, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return data, nil
+	}
+	if w >= h {
+		w, h = size, max(1, h*size/w)
+	} else {
+		w, h = max(1, w*size/h), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
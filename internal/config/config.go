@@ -0,0 +1,417 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Pertsaa/go-radio/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to the upper-cased key path of a setting to form its
+// environment variable, e.g. GORADIO_RADIO_ADDR for radio.addr.
+const EnvPrefix = "GORADIO_"
+
+type Config struct {
+	Log       LogConfig       `yaml:"log" json:"log" toml:"log"`
+	Radio     RadioConfig     `yaml:"radio" json:"radio" toml:"radio"`
+	App       AppConfig       `yaml:"app" json:"app" toml:"app"`
+	Discord   DiscordConfig   `yaml:"discord" json:"discord" toml:"discord"`
+	Formatter FormatterConfig `yaml:"formatter" json:"formatter" toml:"formatter"`
+}
+
+type LogConfig struct {
+	Format string `yaml:"format" json:"format" toml:"format"`
+	Level  string `yaml:"level" json:"level" toml:"level"`
+}
+
+type RadioConfig struct {
+	Addr             string                   `yaml:"addr" json:"addr" toml:"addr"`
+	DataDir          string                   `yaml:"data_dir" json:"data_dir" toml:"data_dir"`
+	AdminKey         string                   `yaml:"admin_key" json:"admin_key" toml:"admin_key"`
+	PublicURL        string                   `yaml:"public_url" json:"public_url" toml:"public_url"`
+	StorePath        string                   `yaml:"store_path" json:"store_path" toml:"store_path"`
+	HistoryRetention Duration                 `yaml:"history_retention" json:"history_retention" toml:"history_retention"`
+	LibraryScan      Duration                 `yaml:"library_scan_interval" json:"library_scan_interval" toml:"library_scan_interval"`
+	CORS             CORSConfig               `yaml:"cors" json:"cors" toml:"cors"`
+	CORSRoutes       []CORSRouteConfig        `yaml:"cors_routes" json:"cors_routes" toml:"cors_routes"`
+	ShutdownTimeout  Duration                 `yaml:"shutdown_timeout" json:"shutdown_timeout" toml:"shutdown_timeout"`
+	BufferLength     int                      `yaml:"buffer_length" json:"buffer_length" toml:"buffer_length"`
+	ChunkSize        int                      `yaml:"chunk_size" json:"chunk_size" toml:"chunk_size"`
+	TickInterval     Duration                 `yaml:"tick_interval" json:"tick_interval" toml:"tick_interval"`
+	Mode             string                   `yaml:"mode" json:"mode" toml:"mode"`
+	Epoch            time.Time                `yaml:"epoch" json:"epoch" toml:"epoch"`
+	IdleAfter        Duration                 `yaml:"idle_after" json:"idle_after" toml:"idle_after"`
+	WriteTimeout     Duration                 `yaml:"write_timeout" json:"write_timeout" toml:"write_timeout"`
+	QueueSize        int                      `yaml:"queue_size" json:"queue_size" toml:"queue_size"`
+	QueuePolicy      string                   `yaml:"queue_policy" json:"queue_policy" toml:"queue_policy"`
+	MaxLag           Duration                 `yaml:"max_lag" json:"max_lag" toml:"max_lag"`
+	TimeShift        Duration                 `yaml:"time_shift" json:"time_shift" toml:"time_shift"`
+	Limits           LimitsConfig             `yaml:"limits" json:"limits" toml:"limits"`
+	Recordings       RecordingsConfig         `yaml:"recordings" json:"recordings" toml:"recordings"`
+	Channels         map[string]ChannelConfig `yaml:"channels" json:"channels" toml:"channels"`
+}
+
+type LimitsConfig struct {
+	MaxListeners        int `yaml:"max_listeners" json:"max_listeners" toml:"max_listeners"`
+	MaxChannelListeners int `yaml:"max_channel_listeners" json:"max_channel_listeners" toml:"max_channel_listeners"`
+	MaxListenersPerIP   int `yaml:"max_listeners_per_ip" json:"max_listeners_per_ip" toml:"max_listeners_per_ip"`
+}
+
+// RecordingsConfig controls channel recordings. Dir defaults to a hidden
+// directory inside data_dir. Continuous lists channel names recorded at all
+// times into files rotated every Rotate. Recordings shorter than
+// FeedMinDuration are left out of podcast feeds.
+type RecordingsConfig struct {
+	Dir             string                    `yaml:"dir" json:"dir" toml:"dir"`
+	Rotate          Duration                  `yaml:"rotate" json:"rotate" toml:"rotate"`
+	MaxAge          Duration                  `yaml:"max_age" json:"max_age" toml:"max_age"`
+	MaxSizeMB       int                       `yaml:"max_size_mb" json:"max_size_mb" toml:"max_size_mb"`
+	FeedMinDuration Duration                  `yaml:"feed_min_duration" json:"feed_min_duration" toml:"feed_min_duration"`
+	Continuous      []string                  `yaml:"continuous" json:"continuous" toml:"continuous"`
+	Schedule        []RecordingScheduleConfig `yaml:"schedule" json:"schedule" toml:"schedule"`
+}
+
+// RecordingScheduleConfig records a channel for Duration starting at Start
+// ("HH:MM", local time) every day, or only on Weekday when set.
+type RecordingScheduleConfig struct {
+	Channel  string   `yaml:"channel" json:"channel" toml:"channel"`
+	Weekday  string   `yaml:"weekday" json:"weekday" toml:"weekday"`
+	Start    string   `yaml:"start" json:"start" toml:"start"`
+	Duration Duration `yaml:"duration" json:"duration" toml:"duration"`
+}
+
+// ParseWeekday returns the scheduled weekday, or nil for every day.
+func (s RecordingScheduleConfig) ParseWeekday() (*time.Weekday, error) {
+	if s.Weekday == "" {
+		return nil, nil
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s.Weekday, d.String()) || strings.EqualFold(s.Weekday, d.String()[:3]) {
+			return &d, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown weekday %q", s.Weekday)
+}
+
+// ChannelConfig overrides radio settings for a single channel, keyed by the
+// channel directory name. Zero values inherit the radio-wide setting, except
+// that IdleAfter, TimeShift and MaxListeners are pointers so an explicit 0
+// turns them off for the channel.
+type ChannelConfig struct {
+	BufferLength int       `yaml:"buffer_length" json:"buffer_length" toml:"buffer_length"`
+	ChunkSize    int       `yaml:"chunk_size" json:"chunk_size" toml:"chunk_size"`
+	TickInterval Duration  `yaml:"tick_interval" json:"tick_interval" toml:"tick_interval"`
+	Mode         string    `yaml:"mode" json:"mode" toml:"mode"`
+	Epoch        time.Time `yaml:"epoch" json:"epoch" toml:"epoch"`
+	IdleAfter    *Duration `yaml:"idle_after" json:"idle_after" toml:"idle_after"`
+	WriteTimeout Duration  `yaml:"write_timeout" json:"write_timeout" toml:"write_timeout"`
+	QueueSize    int       `yaml:"queue_size" json:"queue_size" toml:"queue_size"`
+	QueuePolicy  string    `yaml:"queue_policy" json:"queue_policy" toml:"queue_policy"`
+	MaxLag       Duration  `yaml:"max_lag" json:"max_lag" toml:"max_lag"`
+	TimeShift    *Duration `yaml:"time_shift" json:"time_shift" toml:"time_shift"`
+	MaxListeners *int      `yaml:"max_listeners" json:"max_listeners" toml:"max_listeners"`
+}
+
+// CORSConfig is a cross-origin policy. Origins are exact ("https://a.com"),
+// wildcard subdomains ("https://*.a.com") or "*" for any origin.
+type CORSConfig struct {
+	Origins          []string `yaml:"origins" json:"origins" toml:"origins"`
+	AllowCredentials bool     `yaml:"allow_credentials" json:"allow_credentials" toml:"allow_credentials"`
+	Methods          []string `yaml:"methods" json:"methods" toml:"methods"`
+	Headers          []string `yaml:"headers" json:"headers" toml:"headers"`
+	ExposeHeaders    []string `yaml:"expose_headers" json:"expose_headers" toml:"expose_headers"`
+	MaxAge           Duration `yaml:"max_age" json:"max_age" toml:"max_age"`
+}
+
+// CORSRouteConfig applies its own policy to request paths matching Path,
+// which may contain path.Match wildcards.
+type CORSRouteConfig struct {
+	Path       string `yaml:"path" json:"path" toml:"path"`
+	CORSConfig `yaml:",inline"`
+}
+
+type AppConfig struct {
+	Addr string     `yaml:"addr" json:"addr" toml:"addr"`
+	CORS CORSConfig `yaml:"cors" json:"cors" toml:"cors"`
+}
+
+type DiscordConfig struct {
+	Token        string `yaml:"token" json:"token" toml:"token"`
+	RadioBaseURL string `yaml:"radio_base_url" json:"radio_base_url" toml:"radio_base_url"`
+}
+
+type FormatterConfig struct {
+	Bitrate string `yaml:"bitrate" json:"bitrate" toml:"bitrate"`
+}
+
+// Duration is a time.Duration read from strings such as "170ms" or "10s".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func defaultCORS() CORSConfig {
+	return CORSConfig{
+		Origins: []string{
+			"http://localhost:3000",
+			"http://radio.local:3000",
+		},
+		AllowCredentials: true,
+		Methods:          []string{"QUERY", "GET", "POST", "PATCH", "DELETE", "OPTIONS"},
+		Headers:          []string{"Content-Type", "Authorization", "X-Request-ID"},
+		ExposeHeaders:    []string{"Authorization", "X-Request-ID"},
+		MaxAge:           Duration{10 * time.Minute},
+	}
+}
+
+// defaultCORSRoutes makes the audio streams playable from any site without
+// credentials, while the rest of the API keeps the default policy.
+func defaultCORSRoutes() []CORSRouteConfig {
+	return []CORSRouteConfig{
+		{
+			Path: "/radio/channels/*/stream",
+			CORSConfig: CORSConfig{
+				Origins: []string{"*"},
+				Methods: []string{"GET"},
+				MaxAge:  Duration{time.Hour},
+			},
+		},
+	}
+}
+
+func Default() *Config {
+	return &Config{
+		Log: LogConfig{
+			Format: "text",
+			Level:  "info",
+		},
+		Radio: RadioConfig{
+			Addr:             ":8080",
+			CORS:             defaultCORS(),
+			CORSRoutes:       defaultCORSRoutes(),
+			ShutdownTimeout:  Duration{10 * time.Second},
+			HistoryRetention: Duration{30 * 24 * time.Hour},
+			LibraryScan:      Duration{10 * time.Minute},
+			BufferLength:     28,
+			ChunkSize:        1024 * 4,
+			TickInterval:     Duration{170 * time.Millisecond},
+			Mode:             "sequential",
+			WriteTimeout:     Duration{10 * time.Second},
+			QueueSize:        8,
+			QueuePolicy:      "drop_oldest",
+			MaxLag:           Duration{10 * time.Second},
+			Recordings: RecordingsConfig{
+				Rotate:          Duration{time.Hour},
+				FeedMinDuration: Duration{time.Minute},
+			},
+		},
+		App: AppConfig{
+			Addr: ":3000",
+			CORS: defaultCORS(),
+		},
+		Discord: DiscordConfig{
+			RadioBaseURL: "http://localhost:8080",
+		},
+		Formatter: FormatterConfig{
+			Bitrate: "192k",
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the optional config file at
+// path, environment variables and finally the given overrides, which are
+// usually collected from command-line flags. The result is validated.
+func Load(path string, overrides Overrides) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	for _, key := range overrides.keys() {
+		if err := cfg.Set(key, overrides[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), c)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown setting %q", md.Undecoded()[0].String())
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+var bitratePattern = regexp.MustCompile(`^[1-9][0-9]*k$`)
+
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, err := logging.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level: invalid level %q", c.Log.Level)
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: must be text or json")
+
+	check(c.Radio.Addr != "", "radio.addr: must not be empty")
+	if c.Radio.PublicURL != "" {
+		u, err := url.Parse(c.Radio.PublicURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "radio.public_url: must be an http(s) URL")
+	}
+	check(c.Radio.ShutdownTimeout.Duration > 0, "radio.shutdown_timeout: must be positive")
+	check(c.Radio.HistoryRetention.Duration >= 0, "radio.history_retention: must not be negative")
+	check(c.Radio.LibraryScan.Duration >= 0, "radio.library_scan_interval: must not be negative")
+	check(c.Radio.BufferLength > 0, "radio.buffer_length: must be positive")
+	check(c.Radio.ChunkSize > 0, "radio.chunk_size: must be positive")
+	check(c.Radio.TickInterval.Duration > 0, "radio.tick_interval: must be positive")
+	check(validMode(c.Radio.Mode), "radio.mode: must be sequential or timeline")
+	check(c.Radio.IdleAfter.Duration >= 0, "radio.idle_after: must not be negative")
+	check(c.Radio.WriteTimeout.Duration > 0, "radio.write_timeout: must be positive")
+	check(c.Radio.QueueSize > 0, "radio.queue_size: must be positive")
+	check(validQueuePolicy(c.Radio.QueuePolicy), "radio.queue_policy: must be drop_oldest, skip_to_live or disconnect")
+	check(c.Radio.MaxLag.Duration > 0, "radio.max_lag: must be positive")
+	check(c.Radio.TimeShift.Duration >= 0, "radio.time_shift: must not be negative")
+	check(c.Radio.Limits.MaxListeners >= 0, "radio.limits.max_listeners: must not be negative")
+	check(c.Radio.Limits.MaxChannelListeners >= 0, "radio.limits.max_channel_listeners: must not be negative")
+	check(c.Radio.Limits.MaxListenersPerIP >= 0, "radio.limits.max_listeners_per_ip: must not be negative")
+
+	for name, ch := range c.Radio.Channels {
+		check(ch.BufferLength >= 0, "radio.channels.%s.buffer_length: must not be negative", name)
+		check(ch.ChunkSize >= 0, "radio.channels.%s.chunk_size: must not be negative", name)
+		check(ch.TickInterval.Duration >= 0, "radio.channels.%s.tick_interval: must not be negative", name)
+		check(ch.Mode == "" || validMode(ch.Mode), "radio.channels.%s.mode: must be sequential or timeline", name)
+		check(ch.IdleAfter == nil || ch.IdleAfter.Duration >= 0, "radio.channels.%s.idle_after: must not be negative", name)
+		check(ch.WriteTimeout.Duration >= 0, "radio.channels.%s.write_timeout: must not be negative", name)
+		check(ch.QueueSize >= 0, "radio.channels.%s.queue_size: must not be negative", name)
+		check(ch.QueuePolicy == "" || validQueuePolicy(ch.QueuePolicy), "radio.channels.%s.queue_policy: must be drop_oldest, skip_to_live or disconnect", name)
+		check(ch.MaxLag.Duration >= 0, "radio.channels.%s.max_lag: must not be negative", name)
+		check(ch.TimeShift == nil || ch.TimeShift.Duration >= 0, "radio.channels.%s.time_shift: must not be negative", name)
+		check(ch.MaxListeners == nil || *ch.MaxListeners >= 0, "radio.channels.%s.max_listeners: must not be negative", name)
+	}
+
+	rec := c.Radio.Recordings
+	check(rec.Rotate.Duration >= time.Minute, "radio.recordings.rotate: must be at least 1m")
+	check(rec.MaxAge.Duration >= 0, "radio.recordings.max_age: must not be negative")
+	check(rec.MaxSizeMB >= 0, "radio.recordings.max_size_mb: must not be negative")
+	check(rec.FeedMinDuration.Duration >= 0, "radio.recordings.feed_min_duration: must not be negative")
+	for i, s := range rec.Schedule {
+		_, err := s.ParseWeekday()
+		check(s.Channel != "", "radio.recordings.schedule[%d].channel: must not be empty", i)
+		check(err == nil, "radio.recordings.schedule[%d].weekday: must be a weekday name such as monday", i)
+		_, err = time.Parse("15:04", s.Start)
+		check(err == nil, "radio.recordings.schedule[%d].start: must look like 18:00", i)
+		check(s.Duration.Duration > 0, "radio.recordings.schedule[%d].duration: must be positive", i)
+	}
+
+	errs = append(errs, c.Radio.CORS.validate("radio.cors"))
+	for i, route := range c.Radio.CORSRoutes {
+		_, err := path.Match(route.Path, "/")
+		check(route.Path != "" && err == nil, "radio.cors_routes[%d].path: invalid pattern %q", i, route.Path)
+		errs = append(errs, route.CORSConfig.validate(fmt.Sprintf("radio.cors_routes[%d]", i)))
+	}
+
+	check(c.App.Addr != "", "app.addr: must not be empty")
+	errs = append(errs, c.App.CORS.validate("app.cors"))
+
+	u, err := url.Parse(c.Discord.RadioBaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "discord.radio_base_url: must be an http(s) URL")
+
+	check(bitratePattern.MatchString(c.Formatter.Bitrate), "formatter.bitrate: must look like 192k")
+
+	return errors.Join(errs...)
+}
+
+func validMode(mode string) bool {
+	return mode == "sequential" || mode == "timeline"
+}
+
+func validQueuePolicy(policy string) bool {
+	switch policy {
+	case "drop_oldest", "skip_to_live", "disconnect":
+		return true
+	}
+	return false
+}
+
+func (c CORSConfig) validate(key string) error {
+	var errs []error
+	for _, origin := range c.Origins {
+		if origin == "*" {
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.Contains(host, "/") || strings.Count(origin, "*") > 1 {
+			errs = append(errs, fmt.Errorf("%s.origins: invalid origin %q", key, origin))
+		}
+	}
+	if c.MaxAge.Duration < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_age: must not be negative", key))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,382 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		env       map[string]string
+		overrides Overrides
+		check     func(t *testing.T, cfg *Config)
+		err       string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.Addr != ":8080" || cfg.Radio.QueuePolicy != "drop_oldest" {
+					t.Errorf("got addr %q and queue policy %q", cfg.Radio.Addr, cfg.Radio.QueuePolicy)
+				}
+			},
+		},
+		{
+			name: "yaml",
+			file: "radio.yaml",
+			content: `radio:
+  addr: ":9000"
+  tick_interval: 200ms
+  channels:
+    jazz:
+      time_shift: 0s
+      max_listeners: 5
+`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.Addr != ":9000" || cfg.Radio.TickInterval.Duration != 200*time.Millisecond {
+					t.Errorf("got addr %q and tick interval %v", cfg.Radio.Addr, cfg.Radio.TickInterval)
+				}
+				jazz := cfg.Radio.Channels["jazz"]
+				if jazz.TimeShift == nil || jazz.TimeShift.Duration != 0 {
+					t.Errorf("jazz time_shift = %v, want explicit 0", jazz.TimeShift)
+				}
+				if jazz.MaxListeners == nil || *jazz.MaxListeners != 5 {
+					t.Errorf("jazz max_listeners = %v, want 5", jazz.MaxListeners)
+				}
+				if jazz.IdleAfter != nil {
+					t.Errorf("jazz idle_after = %v, want unset", jazz.IdleAfter)
+				}
+			},
+		},
+		{
+			name:    "toml",
+			file:    "radio.toml",
+			content: "[radio]\naddr = \":9001\"\nmax_lag = \"5s\"\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.Addr != ":9001" || cfg.Radio.MaxLag.Duration != 5*time.Second {
+					t.Errorf("got addr %q and max lag %v", cfg.Radio.Addr, cfg.Radio.MaxLag)
+				}
+			},
+		},
+		{
+			name:    "json",
+			file:    "radio.json",
+			content: `{"radio": {"addr": ":9002", "chunk_size": 2048}}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.Addr != ":9002" || cfg.Radio.ChunkSize != 2048 {
+					t.Errorf("got addr %q and chunk size %d", cfg.Radio.Addr, cfg.Radio.ChunkSize)
+				}
+			},
+		},
+		{
+			name:    "empty yaml",
+			file:    "radio.yml",
+			content: "",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.Addr != ":8080" {
+					t.Errorf("addr = %q, want the default", cfg.Radio.Addr)
+				}
+			},
+		},
+		{
+			name:      "env and overrides",
+			file:      "radio.yaml",
+			content:   "radio:\n  addr: \":9000\"\n  admin_key: file\n",
+			env:       map[string]string{"GORADIO_RADIO_ADDR": ":9100", "GORADIO_RADIO_ADMIN_KEY": "env"},
+			overrides: Overrides{"radio.addr": ":9200"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.Addr != ":9200" {
+					t.Errorf("addr = %q, want the override", cfg.Radio.Addr)
+				}
+				if cfg.Radio.AdminKey != "env" {
+					t.Errorf("admin_key = %q, want the environment value", cfg.Radio.AdminKey)
+				}
+			},
+		},
+		{
+			name:    "unknown yaml key",
+			file:    "radio.yaml",
+			content: "radio:\n  adress: \":9000\"\n",
+			err:     "field adress not found",
+		},
+		{
+			name:    "unknown toml key",
+			file:    "radio.toml",
+			content: "[radio]\nadress = \":9000\"\n",
+			err:     `unknown setting "radio.adress"`,
+		},
+		{
+			name:    "unknown json key",
+			file:    "radio.json",
+			content: `{"radio": {"adress": ":9000"}}`,
+			err:     `unknown field "adress"`,
+		},
+		{
+			name:    "unsupported format",
+			file:    "radio.ini",
+			content: "addr=:9000",
+			err:     `unsupported config file format ".ini"`,
+		},
+		{
+			name:      "unknown override",
+			overrides: Overrides{"radio.nope": "1"},
+			err:       `unknown setting "radio.nope"`,
+		},
+		{
+			name:    "invalid result",
+			file:    "radio.yaml",
+			content: "radio:\n  mode: shuffle\n",
+			err:     "radio.mode: must be sequential or timeline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			var path string
+			if tt.file != "" {
+				path = writeConfig(t, tt.file, tt.content)
+			}
+
+			cfg, err := Load(path, tt.overrides)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(t *testing.T, cfg *Config)
+		err   string
+	}{
+		{
+			name: "scalars",
+			env: map[string]string{
+				"GORADIO_RADIO_BUFFER_LENGTH":          "12",
+				"GORADIO_RADIO_TICK_INTERVAL":          "90ms",
+				"GORADIO_RADIO_IDLE_AFTER":             "90s",
+				"GORADIO_RADIO_LIMITS_MAX_LISTENERS":   "100",
+				"GORADIO_RADIO_CORS_ALLOW_CREDENTIALS": "false",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				r := cfg.Radio
+				if r.BufferLength != 12 || r.TickInterval.Duration != 90*time.Millisecond || r.IdleAfter.Duration != 90*time.Second || r.Limits.MaxListeners != 100 ||
+					r.CORS.AllowCredentials {
+					t.Errorf("got %+v", r)
+				}
+			},
+		},
+		{
+			name: "lists",
+			env:  map[string]string{"GORADIO_APP_CORS_ORIGINS": " https://a.com, ,https://b.com "},
+			check: func(t *testing.T, cfg *Config) {
+				if want := []string{"https://a.com", "https://b.com"}; !slices.Equal(cfg.App.CORS.Origins, want) {
+					t.Errorf("origins = %q, want %q", cfg.App.CORS.Origins, want)
+				}
+			},
+		},
+		{
+			name: "empty list",
+			env:  map[string]string{"GORADIO_RADIO_CORS_ORIGINS": ""},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.CORS.Origins == nil || len(cfg.Radio.CORS.Origins) != 0 {
+					t.Errorf("cors origins = %#v, want an empty list", cfg.Radio.CORS.Origins)
+				}
+			},
+		},
+		{
+			name: "sections from the file only",
+			env:  map[string]string{"GORADIO_RADIO_CHANNELS": "jazz", "GORADIO_RADIO_CORS_ROUTES": "x"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Radio.Channels != nil || len(cfg.Radio.CORSRoutes) != 1 {
+					t.Errorf("channels = %v, cors routes = %v", cfg.Radio.Channels, cfg.Radio.CORSRoutes)
+				}
+			},
+		},
+		{
+			name: "invalid integer",
+			env:  map[string]string{"GORADIO_RADIO_CHUNK_SIZE": "many"},
+			err:  `GORADIO_RADIO_CHUNK_SIZE: radio.chunk_size: invalid integer "many"`,
+		},
+		{
+			name: "invalid duration",
+			env:  map[string]string{"GORADIO_RADIO_SHUTDOWN_TIMEOUT": "soon"},
+			err:  "GORADIO_RADIO_SHUTDOWN_TIMEOUT: radio.shutdown_timeout:",
+		},
+		{
+			name: "invalid boolean",
+			env:  map[string]string{"GORADIO_APP_CORS_ALLOW_CREDENTIALS": "maybe"},
+			err:  `invalid boolean "maybe"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			err := cfg.applyEnv(func(key string) (string, bool) {
+				value, ok := tt.env[key]
+				return value, ok
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("applyEnv() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyEnv() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{name: "log level", modify: func(c *Config) { c.Log.Level = "loud" }, err: `log.level: invalid level "loud"`},
+		{name: "log format", modify: func(c *Config) { c.Log.Format = "xml" }, err: "log.format: must be text or json"},
+		{name: "shutdown timeout", modify: func(c *Config) { c.Radio.ShutdownTimeout.Duration = 0 }, err: "radio.shutdown_timeout: must be positive"},
+		{name: "buffer length", modify: func(c *Config) { c.Radio.BufferLength = 0 }, err: "radio.buffer_length: must be positive"},
+		{name: "queue policy", modify: func(c *Config) { c.Radio.QueuePolicy = "block" }, err: "radio.queue_policy: must be drop_oldest, skip_to_live or disconnect"},
+		{name: "time shift", modify: func(c *Config) { c.Radio.TimeShift.Duration = -time.Second }, err: "radio.time_shift: must not be negative"},
+		{name: "history retention", modify: func(c *Config) { c.Radio.HistoryRetention.Duration = -time.Hour }, err: "radio.history_retention: must not be negative"},
+		{name: "limits", modify: func(c *Config) { c.Radio.Limits.MaxListenersPerIP = -1 }, err: "radio.limits.max_listeners_per_ip: must not be negative"},
+		{
+			name: "channel overrides",
+			modify: func(c *Config) {
+				zero := 0
+				c.Radio.Channels = map[string]ChannelConfig{
+					"jazz": {Mode: "timeline", ChunkSize: 2048, TimeShift: &Duration{}, MaxListeners: &zero},
+				}
+			},
+		},
+		{
+			name: "channel tick interval",
+			modify: func(c *Config) {
+				c.Radio.Channels = map[string]ChannelConfig{"jazz": {TickInterval: Duration{-time.Second}}}
+			},
+			err: "radio.channels.jazz.tick_interval: must not be negative",
+		},
+		{
+			name:   "channel mode",
+			modify: func(c *Config) { c.Radio.Channels = map[string]ChannelConfig{"jazz": {Mode: "shuffle"}} },
+			err:    "radio.channels.jazz.mode: must be sequential or timeline",
+		},
+		{
+			name: "channel idle after",
+			modify: func(c *Config) {
+				c.Radio.Channels = map[string]ChannelConfig{"jazz": {IdleAfter: &Duration{-time.Second}}}
+			},
+			err: "radio.channels.jazz.idle_after: must not be negative",
+		},
+		{
+			name: "channel write timeout",
+			modify: func(c *Config) {
+				c.Radio.Channels = map[string]ChannelConfig{"jazz": {WriteTimeout: Duration{-time.Second}}}
+			},
+			err: "radio.channels.jazz.write_timeout: must not be negative",
+		},
+		{
+			name:   "channel queue policy",
+			modify: func(c *Config) { c.Radio.Channels = map[string]ChannelConfig{"jazz": {QueuePolicy: "block"}} },
+			err:    "radio.channels.jazz.queue_policy: must be drop_oldest, skip_to_live or disconnect",
+		},
+		{
+			name: "channel max listeners",
+			modify: func(c *Config) {
+				minusOne := -1
+				c.Radio.Channels = map[string]ChannelConfig{"jazz": {MaxListeners: &minusOne}}
+			},
+			err: "radio.channels.jazz.max_listeners: must not be negative",
+		},
+		{
+			name:   "recording rotation",
+			modify: func(c *Config) { c.Radio.Recordings.Rotate.Duration = time.Second },
+			err:    "radio.recordings.rotate: must be at least 1m",
+		},
+		{
+			name: "recording schedule",
+			modify: func(c *Config) {
+				c.Radio.Recordings.Schedule = []RecordingScheduleConfig{{Channel: "jazz", Weekday: "someday", Start: "25:00"}}
+			},
+			err: "radio.recordings.schedule[0].weekday: must be a weekday name such as monday\n" +
+				"radio.recordings.schedule[0].start: must look like 18:00\n" +
+				"radio.recordings.schedule[0].duration: must be positive",
+		},
+		{
+			name:   "cors origin",
+			modify: func(c *Config) { c.App.CORS.Origins = []string{"https://*.*.a.com"} },
+			err:    `app.cors.origins: invalid origin "https://*.*.a.com"`,
+		},
+		{
+			name:   "cors max age",
+			modify: func(c *Config) { c.Radio.CORS.MaxAge.Duration = -time.Second },
+			err:    "radio.cors.max_age: must not be negative",
+		},
+		{
+			name:   "cors route path",
+			modify: func(c *Config) { c.Radio.CORSRoutes = []CORSRouteConfig{{Path: "/a/["}} },
+			err:    `radio.cors_routes[0].path: invalid pattern "/a/["`,
+		},
+		{
+			name:   "radio base url",
+			modify: func(c *Config) { c.Discord.RadioBaseURL = "radio.example.com" },
+			err:    "discord.radio_base_url: must be an http(s) URL",
+		},
+		{
+			name:   "bitrate",
+			modify: func(c *Config) { c.Formatter.Bitrate = "192" },
+			err:    "formatter.bitrate: must look like 192k",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
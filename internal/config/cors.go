@@ -0,0 +1,25 @@
+package config
+
+import "github.com/Pertsaa/go-radio/internal/middleware"
+
+func (c CORSConfig) Options() middleware.CORSOptions {
+	return middleware.CORSOptions{
+		Origins:          c.Origins,
+		AllowCredentials: c.AllowCredentials,
+		Methods:          c.Methods,
+		Headers:          c.Headers,
+		ExposeHeaders:    c.ExposeHeaders,
+		MaxAge:           c.MaxAge.Duration,
+	}
+}
+
+func (c RadioConfig) CORSRouteOptions() []middleware.CORSRoute {
+	routes := make([]middleware.CORSRoute, 0, len(c.CORSRoutes))
+	for _, route := range c.CORSRoutes {
+		routes = append(routes, middleware.CORSRoute{
+			Path:    route.Path,
+			Options: route.CORSConfig.Options(),
+		})
+	}
+	return routes
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Overrides maps setting keys such as "radio.addr" to raw values. They are
+// applied on top of the config file and environment.
+type Overrides map[string]string
+
+// Flag registers a command-line flag that overrides the setting at key.
+func (o Overrides) Flag(fs *flag.FlagSet, name, key, usage string) {
+	fs.Func(name, usage, func(value string) error {
+		o[key] = value
+		return nil
+	})
+}
+
+func (o Overrides) keys() []string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Set assigns a raw value to the setting at a dotted key path. Lists are
+// comma separated.
+func (c *Config) Set(key, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(key, ".") {
+		field, ok := fieldByKey(v, name)
+		if !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		v = field
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// applyEnv sets every scalar or list setting that has an environment
+// variable defined.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for _, key := range settingKeys(reflect.TypeOf(*c), "") {
+		env := EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if value, ok := lookup(env); ok {
+			if err := c.Set(key, value); err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func settingKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key := prefix + keyName(f)
+		switch {
+		case reflect.PointerTo(f.Type).Implements(textUnmarshalerType):
+			keys = append(keys, key)
+		case f.Type.Kind() == reflect.Struct:
+			keys = append(keys, settingKeys(f.Type, key+".")...)
+		case f.Type.Kind() == reflect.Map,
+			f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() != reflect.String:
+			// Per-channel and per-route sections are only read from the config file.
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func keyName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+func fieldByKey(v reflect.Value, name string) (reflect.Value, bool) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	for i := range v.NumField() {
+		if keyName(v.Type().Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func setValue(v reflect.Value, value string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", v.Type())
+		}
+		items := []string{}
+		for item := range strings.SplitSeq(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
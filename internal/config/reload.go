@@ -0,0 +1,35 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// OnReload reloads the configuration on every SIGHUP until the context is
+// done and passes it to fn. Invalid configurations are logged and skipped.
+func OnReload(ctx context.Context, path string, overrides Overrides, fn func(*Config)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+			}
+
+			cfg, err := Load(path, overrides)
+			if err != nil {
+				slog.Error("failed to reload configuration", "error", err)
+				continue
+			}
+			fn(cfg)
+			slog.Info("configuration reloaded")
+		}
+	}()
+}
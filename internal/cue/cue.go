@@ -0,0 +1,212 @@
+// Package cue parses cue sheets, which list the tracks inside a long audio
+// file such as a DJ mix.
+package cue
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// framesPerSecond is the resolution of cue sheet times, inherited from CDs.
+const framesPerSecond = 75
+
+// maxSheetSize bounds how much is read as a cue sheet.
+const maxSheetSize = 1 << 20
+
+var ErrNoTracks = errors.New("cue sheet has no tracks")
+
+type Sheet struct {
+	Title     string
+	Performer string
+	Tracks    []Track
+}
+
+type Track struct {
+	Number    int
+	File      string
+	Title     string
+	Performer string
+	// Start is the INDEX 01 of the track, from the start of its file.
+	Start time.Duration
+}
+
+// Parse reads a cue sheet. Text that is not valid UTF-8 is taken to be
+// Latin-1, which older tools write.
+func Parse(r io.Reader) (*Sheet, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSheetSize))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	text := string(data)
+	if !utf8.ValidString(text) {
+		text = latin1(data)
+	}
+
+	sheet := &Sheet{}
+	var file string
+	var track *Track
+	hasIndex := false
+
+	// finish checks the track being read before the next one starts.
+	finish := func() error {
+		if track == nil {
+			return nil
+		}
+		if !hasIndex {
+			return fmt.Errorf("track %d has no INDEX 01", track.Number)
+		}
+		sheet.Tracks = append(sheet.Tracks, *track)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		fields := split(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "TITLE":
+			if len(fields) > 1 {
+				if track != nil {
+					track.Title = fields[1]
+				} else {
+					sheet.Title = fields[1]
+				}
+			}
+		case "PERFORMER":
+			if len(fields) > 1 {
+				if track != nil {
+					track.Performer = fields[1]
+				} else {
+					sheet.Performer = fields[1]
+				}
+			}
+		case "FILE":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: FILE without a name", line)
+			}
+			file = fields[1]
+		case "TRACK":
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: TRACK without a number", line)
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid track number %q", line, fields[1])
+			}
+			track, hasIndex = &Track{Number: n, File: file}, false
+		case "INDEX":
+			if track == nil || len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: misplaced INDEX", line)
+			}
+			if fields[1] != "01" && fields[1] != "1" {
+				// INDEX 00 marks the pregap, and higher indexes are
+				// subdivisions of the track.
+				continue
+			}
+			start, err := parseTime(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			track.Start, hasIndex = start, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+
+	if len(sheet.Tracks) == 0 {
+		return nil, ErrNoTracks
+	}
+	return sheet, nil
+}
+
+// TracksOf returns the tracks in the named audio file, in order of their
+// start. A sheet that refers to a single file is taken to describe name even
+// if the file names differ, as sheets often name the original recording.
+func (s *Sheet) TracksOf(name string) []Track {
+	files := map[string]bool{}
+	for _, t := range s.Tracks {
+		files[t.File] = true
+	}
+
+	var tracks []Track
+	for _, t := range s.Tracks {
+		if len(files) == 1 || strings.EqualFold(filepath.Base(t.File), name) {
+			if n := len(tracks); n > 0 && t.Start < tracks[n-1].Start {
+				// Out of order; keep what makes sense.
+				continue
+			}
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// parseTime parses an mm:ss:ff time, where ff counts 75ths of a second.
+func parseTime(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var n [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		n[i] = v
+	}
+	if n[1] >= 60 || n[2] >= framesPerSecond {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	frames := (n[0]*60+n[1])*framesPerSecond + n[2]
+	return time.Duration(frames) * time.Second / framesPerSecond, nil
+}
+
+// split breaks a line into words, keeping double-quoted strings together.
+func split(line string) []string {
+	var fields []string
+	line = strings.TrimSpace(line)
+	for line != "" {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				fields = append(fields, line[1:])
+				break
+			}
+			fields = append(fields, line[1:1+end])
+			line = strings.TrimSpace(line[2+end:])
+			continue
+		}
+		word, rest, _ := strings.Cut(line, " ")
+		fields = append(fields, strings.TrimSpace(word))
+		line = strings.TrimSpace(rest)
+	}
+	return fields
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
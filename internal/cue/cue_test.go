@@ -0,0 +1,197 @@
+package cue
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+		want  *Sheet
+		err   string
+	}{
+		{
+			name: "mix",
+			sheet: `REM GENRE Electronic
+PERFORMER "DJ Test"
+TITLE "Late Mix"
+FILE "mix.mp3" MP3
+  TRACK 01 AUDIO
+    TITLE "Opener"
+    PERFORMER "Artist One"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Second"
+    INDEX 00 03:58:00
+    INDEX 01 04:00:37
+`,
+			want: &Sheet{
+				Title:     "Late Mix",
+				Performer: "DJ Test",
+				Tracks: []Track{
+					{Number: 1, File: "mix.mp3", Title: "Opener", Performer: "Artist One", Start: 0},
+					{Number: 2, File: "mix.mp3", Title: "Second", Start: 4*time.Minute + 37*time.Second/75},
+				},
+			},
+		},
+		{
+			name:  "byte order mark and lowercase",
+			sheet: "\xef\xbb\xbffile a.mp3 MP3\ntrack 1 audio\ntitle Unquoted\nindex 1 01:00:00\n",
+			want: &Sheet{
+				Tracks: []Track{{Number: 1, File: "a.mp3", Title: "Unquoted", Start: time.Minute}},
+			},
+		},
+		{
+			name:  "latin-1",
+			sheet: "FILE \"a.mp3\" MP3\nTRACK 01 AUDIO\nTITLE \"Caf\xe9\"\nINDEX 01 00:00:00\n",
+			want: &Sheet{
+				Tracks: []Track{{Number: 1, File: "a.mp3", Title: "Café"}},
+			},
+		},
+		{
+			name:  "unterminated quote",
+			sheet: "FILE \"a.mp3\" MP3\nTRACK 01 AUDIO\nTITLE \"Open\nINDEX 01 00:00:00\n",
+			want: &Sheet{
+				Tracks: []Track{{Number: 1, File: "a.mp3", Title: "Open"}},
+			},
+		},
+		{
+			name:  "no tracks",
+			sheet: "TITLE \"Empty\"\n",
+			err:   ErrNoTracks.Error(),
+		},
+		{
+			name:  "track without index",
+			sheet: "FILE a.mp3 MP3\nTRACK 01 AUDIO\nTRACK 02 AUDIO\nINDEX 01 00:00:00\n",
+			err:   "track 1 has no INDEX 01",
+		},
+		{
+			name:  "index outside track",
+			sheet: "FILE a.mp3 MP3\nINDEX 01 00:00:00\n",
+			err:   "line 2: misplaced INDEX",
+		},
+		{
+			name:  "invalid track number",
+			sheet: "FILE a.mp3 MP3\nTRACK one AUDIO\n",
+			err:   `line 2: invalid track number "one"`,
+		},
+		{
+			name:  "invalid time",
+			sheet: "FILE a.mp3 MP3\nTRACK 01 AUDIO\nINDEX 01 00:60:00\n",
+			err:   `line 3: invalid time "00:60:00"`,
+		},
+		{
+			name:  "file without name",
+			sheet: "FILE\n",
+			err:   "line 1: FILE without a name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.sheet))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Parse(strings.NewReader("")); !errors.Is(err, ErrNoTracks) {
+		t.Errorf("Parse() of empty sheet error = %v, want %v", err, ErrNoTracks)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"00:00:00", 0, true},
+		{"01:02:00", time.Minute + 2*time.Second, true},
+		{"00:00:74", 74 * time.Second / 75, true},
+		{"90:00:00", 90 * time.Minute, true},
+		{"00:00:75", 0, false},
+		{"00:60:00", 0, false},
+		{"-1:00:00", 0, false},
+		{"01:02", 0, false},
+		{"aa:bb:cc", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseTime(%q) = %v, %v, want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTracksOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []Track
+		file   string
+		want   []int
+	}{
+		{
+			name:   "single file under another name",
+			tracks: []Track{{Number: 1, File: "original.wav"}, {Number: 2, File: "original.wav", Start: time.Minute}},
+			file:   "mix.mp3",
+			want:   []int{1, 2},
+		},
+		{
+			name: "several files",
+			tracks: []Track{
+				{Number: 1, File: "side-a.mp3"},
+				{Number: 2, File: "side-a.mp3", Start: time.Minute},
+				{Number: 3, File: "dir/Side-B.mp3"},
+			},
+			file: "side-b.mp3",
+			want: []int{3},
+		},
+		{
+			name:   "no match",
+			tracks: []Track{{Number: 1, File: "a.mp3"}, {Number: 2, File: "b.mp3"}},
+			file:   "c.mp3",
+			want:   nil,
+		},
+		{
+			name: "out of order",
+			tracks: []Track{
+				{Number: 1, File: "a.mp3"},
+				{Number: 2, File: "a.mp3", Start: 2 * time.Minute},
+				{Number: 3, File: "a.mp3", Start: time.Minute},
+				{Number: 4, File: "a.mp3", Start: 3 * time.Minute},
+			},
+			file: "a.mp3",
+			want: []int{1, 2, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet := &Sheet{Tracks: tt.tracks}
+			var got []int
+			for _, track := range sheet.TracksOf(tt.file) {
+				got = append(got, track.Number)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TracksOf(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
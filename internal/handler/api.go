@@ -1,31 +1,249 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/radio"
+)
+
+const (
+	listenerRetryAfter  = 30
+	readyMaxSilence     = 5 * time.Second
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+	defaultLibraryLimit = 50
+	maxLibraryLimit     = 500
 )
 
+func (h *APIHandler) HealthHandler(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *APIHandler) ReadyHandler(w http.ResponseWriter, r *http.Request) error {
+	readiness := h.radio.Readiness(readyMaxSilence)
+	if !readiness.Ready {
+		return writeJSON(w, http.StatusServiceUnavailable, readiness)
+	}
+	return writeJSON(w, http.StatusOK, readiness)
+}
+
 func (h *APIHandler) RadioChannelListHandler(w http.ResponseWriter, r *http.Request) error {
 	return writeJSON(w, http.StatusOK, h.radio.GetChannels())
 }
 
+// RadioChannelNowPlayingHandler describes the track on air on a channel.
+func (h *APIHandler) RadioChannelNowPlayingHandler(w http.ResponseWriter, r *http.Request) error {
+	np, err := h.radio.NowPlaying(r.PathValue("channelID"))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	return writeJSON(w, http.StatusOK, np)
+}
+
+// RadioStatsHandler reports listener statistics. Listener IDs are only shown
+// to admins, who can disconnect listeners by them.
+func (h *APIHandler) RadioStatsHandler(w http.ResponseWriter, r *http.Request) error {
+	stats := h.radio.Stats()
+	if !h.isAdmin(r) {
+		stats.RedactListenerIDs()
+	}
+	return writeJSON(w, http.StatusOK, stats)
+}
+
+func (h *APIHandler) RadioChannelStatsHandler(w http.ResponseWriter, r *http.Request) error {
+	stats, err := h.radio.ChannelStats(r.PathValue("channelID"))
+	if err != nil {
+		return err
+	}
+	if !h.isAdmin(r) {
+		stats.RedactListenerIDs()
+	}
+	return writeJSON(w, http.StatusOK, stats)
+}
+
+// RadioChannelHistoryHandler lists previously played tracks, newest first.
+// The list can be paged with limit and before, an RFC 3339 time. Every entry
+// has kind "track": listener requests and jingles are not played yet, so
+// there are no other kinds to record.
+func (h *APIHandler) RadioChannelHistoryHandler(w http.ResponseWriter, r *http.Request) error {
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxHistoryLimit {
+			return NewAPIError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxHistoryLimit))
+		}
+		limit = n
+	}
+
+	var before time.Time
+	if v := r.URL.Query().Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return NewAPIError(http.StatusBadRequest, "before must be an RFC 3339 time")
+		}
+		before = t
+	}
+
+	plays, err := h.radio.History(r.PathValue("channelID"), limit, before)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, plays)
+}
+
+// RadioLibraryHandler searches the music library. q matches words in the
+// title, artist, album or file name; channel, artist, album and genre filter
+// on exact values. Results are paged with limit and offset.
+func (h *APIHandler) RadioLibraryHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	q := radio.LibraryQuery{
+		Text:    query.Get("q"),
+		Channel: query.Get("channel"),
+		Artist:  query.Get("artist"),
+		Album:   query.Get("album"),
+		Genre:   query.Get("genre"),
+		Limit:   defaultLibraryLimit,
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLibraryLimit {
+			return NewAPIError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLibraryLimit))
+		}
+		q.Limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return NewAPIError(http.StatusBadRequest, "offset must not be negative")
+		}
+		q.Offset = n
+	}
+
+	return writeJSON(w, http.StatusOK, h.radio.SearchLibrary(q))
+}
+
 func (h *APIHandler) RadioChannelStreamHandler(w http.ResponseWriter, r *http.Request) error {
 	channelID := r.PathValue("channelID")
 
+	window, err := h.radio.TimeShiftWindow(channelID)
+	if err != nil {
+		return err
+	}
+	shiftStart, shifted, err := parseTimeShift(r, window)
+	if err != nil {
+		return err
+	}
+
+	listener, err := h.radio.Join(r.Context(), channelID, clientAddr(r), r.UserAgent(), h.isAdmin(r))
+	if err != nil {
+		return err
+	}
+	defer h.radio.Leave(r.Context(), listener)
+
 	w.Header().Set("Connection", "Keep-Alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Transfer-Encoding", "chunked")
 	w.Header().Set("Content-Type", "audio/mpeg")
 
-	err := h.radio.WriteBuffer(w, channelID)
+	if shifted {
+		if shiftStart.IsZero() {
+			if shiftStart, err = h.radio.TrackStart(channelID); err != nil {
+				return err
+			}
+		}
+		return h.radio.StreamTimeShift(r.Context(), w, listener, shiftStart)
+	}
+
+	err = h.radio.WriteBuffer(r.Context(), w, listener)
 	if err != nil {
 		return err
 	}
 
-	err = h.radio.StreamChunks(w, channelID)
+	err = h.radio.StreamChunks(r.Context(), w, listener)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// parseTimeShift reads where a time-shifted stream should start from the
+// offset (seconds relative to now), at (an RFC 3339 time) or from=track query
+// parameters. Offsets are clamped to the channel's window. A zero time with
+// shifted set means the start of the current track.
+func parseTimeShift(r *http.Request, window time.Duration) (start time.Time, shifted bool, err error) {
+	query := r.URL.Query()
+	switch {
+	case query.Has("offset"):
+		offset, err := strconv.ParseFloat(query.Get("offset"), 64)
+		if err != nil || offset > 0 || math.IsNaN(offset) {
+			return time.Time{}, false, NewAPIError(http.StatusBadRequest, "offset must be a negative number of seconds")
+		}
+		offset = max(offset, -window.Seconds())
+		return time.Now().Add(time.Duration(offset * float64(time.Second))), true, nil
+	case query.Has("at"):
+		at, err := time.Parse(time.RFC3339Nano, query.Get("at"))
+		if err != nil || at.After(time.Now()) {
+			return time.Time{}, false, NewAPIError(http.StatusBadRequest, "at must be an RFC 3339 time in the past")
+		}
+		return at, true, nil
+	case query.Has("from"):
+		if query.Get("from") != "track" {
+			return time.Time{}, false, NewAPIError(http.StatusBadRequest, "from must be track")
+		}
+		return time.Time{}, true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+// RadioListenerKickHandler ends a listener session. It requires the admin key.
+func (h *APIHandler) RadioListenerKickHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	if err := h.radio.Kick(r.PathValue("listenerID")); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// requireAdmin rejects requests without the admin key.
+func (h *APIHandler) requireAdmin(r *http.Request) error {
+	if !h.isAdmin(r) {
+		return NewAPIError(http.StatusUnauthorized, "Unauthorized")
+	}
+	return nil
+}
+
+// isAdmin reports whether the request carries the configured admin key as a
+// bearer token.
+func (h *APIHandler) isAdmin(r *http.Request) bool {
+	adminKey := *h.adminKey.Load()
+	if adminKey == "" {
+		return false
+	}
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1
+}
+
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pertsaa/go-radio/internal/radio"
+)
+
+func TestIsAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		adminKey string
+		header   string
+		want     bool
+	}{
+		{name: "matching key", adminKey: "secret", header: "Bearer secret", want: true},
+		{name: "wrong key", adminKey: "secret", header: "Bearer guess", want: false},
+		{name: "no header", adminKey: "secret", header: "", want: false},
+		{name: "not a bearer token", adminKey: "secret", header: "Basic secret", want: false},
+		{name: "no admin key configured", adminKey: "", header: "Bearer ", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &APIHandler{}
+			h.SetAdminKey(tt.adminKey)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if got := h.isAdmin(r); got != tt.want {
+				t.Errorf("isAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"192.0.2.1:51234", "192.0.2.1"},
+		{"[2001:db8::1]:51234", "2001:db8::1"},
+		{"192.0.2.1", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if got := clientAddr(r); got != tt.want {
+			t.Errorf("clientAddr(%q) = %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+func TestStreamUnknownChannel(t *testing.T) {
+	h := NewAPIHandler(context.Background(), radio.New(t.TempDir()), "", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /radio/channels/{channelID}/stream", Make(h.RadioChannelStreamHandler))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/radio/channels/missing/stream", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Pertsaa/go-radio/internal/artwork"
+)
+
+// RadioChannelArtworkHandler serves a channel's cover image. The optional size
+// query parameter scales it to fit that many pixels, rounded up to one of the
+// supported thumbnail sizes.
+func (h *APIHandler) RadioChannelArtworkHandler(w http.ResponseWriter, r *http.Request) error {
+	size, err := artworkSize(r)
+	if err != nil {
+		return err
+	}
+
+	img, err := h.radio.ChannelArtwork(r.PathValue("channelID"), size)
+	if err != nil {
+		return err
+	}
+	return serveArtwork(w, r, img)
+}
+
+// RadioArtworkHandler serves cached track artwork by the hash of the image.
+// The URL changes with the image, so it may be cached forever.
+func (h *APIHandler) RadioArtworkHandler(w http.ResponseWriter, r *http.Request) error {
+	size, err := artworkSize(r)
+	if err != nil {
+		return err
+	}
+
+	img, err := h.radio.Artwork(r.PathValue("hash"), size)
+	if err != nil {
+		return err
+	}
+	return serveArtwork(w, r, img)
+}
+
+func artworkSize(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("size")
+	if v == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(v)
+	if err != nil || size <= 0 {
+		return 0, NewAPIError(http.StatusBadRequest, "size must be a positive number of pixels")
+	}
+	return size, nil
+}
+
+// serveArtwork writes an image with caching headers. URLs that carry the
+// image's hash may be cached forever since a new image gets a new URL.
+func serveArtwork(w http.ResponseWriter, r *http.Request, img artwork.Image) error {
+	file, err := os.Open(img.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	cacheControl := "public, max-age=3600"
+	if r.PathValue("hash") == img.Hash || r.URL.Query().Get("v") == img.Hash {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", img.ETag())
+	http.ServeContent(w, r, "", stat.ModTime(), file)
+	return nil
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/radio"
+)
+
+type rssFeed struct {
+	XMLName    xml.Name   `xml:"rss"`
+	Version    string     `xml:"version,attr"`
+	ITunesNS   string     `xml:"xmlns:itunes,attr"`
+	ChaptersNS string     `xml:"xmlns:psc,attr"`
+	AtomNS     string     `xml:"xmlns:atom,attr"`
+	Channel    rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	AtomLink      rssAtomLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	Image         *rssImage   `xml:"image,omitempty"`
+	Author        string      `xml:"itunes:author"`
+	Explicit      string      `xml:"itunes:explicit"`
+	ITunesImage   *rssHref    `xml:"itunes:image,omitempty"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssHref struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Description string       `xml:"description"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+	Duration    string       `xml:"itunes:duration"`
+	Chapters    *rssChapters `xml:"psc:chapters,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssChapters struct {
+	Version  string       `xml:"version,attr"`
+	Chapters []rssChapter `xml:"psc:chapter"`
+}
+
+type rssChapter struct {
+	Start string `xml:"start,attr"`
+	Title string `xml:"title,attr"`
+}
+
+// RadioChannelFeedHandler serves a channel's recordings as a podcast feed.
+func (h *APIHandler) RadioChannelFeedHandler(w http.ResponseWriter, r *http.Request) error {
+	channelID := r.PathValue("channelID")
+	channel, ok := h.radio.GetChannel(channelID)
+	if !ok {
+		return radio.ErrChannelNotFound
+	}
+
+	episodes, err := h.radio.Episodes(channelID)
+	if err != nil {
+		return err
+	}
+
+	base := h.baseURL(r)
+	channelURL := base + "/radio/channels/" + channelID
+
+	feed := rssFeed{
+		Version:    "2.0",
+		ITunesNS:   "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		ChaptersNS: "http://podlove.org/simple-chapters",
+		AtomNS:     "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       channel.DisplayName,
+			Link:        channelURL + "/stream",
+			Description: cmp.Or(channel.Description, "Recorded shows from "+channel.DisplayName+"."),
+			AtomLink:    rssAtomLink{Href: channelURL + "/feed.xml", Rel: "self", Type: "application/rss+xml"},
+			Author:      channel.DisplayName,
+			Explicit:    "false",
+		},
+	}
+
+	if channel.Artwork != "" {
+		artwork := base + channel.Artwork
+		feed.Channel.Image = &rssImage{URL: artwork, Title: channel.DisplayName, Link: feed.Channel.Link}
+		feed.Channel.ITunesImage = &rssHref{Href: artwork}
+	}
+
+	var modTime time.Time
+	for _, ep := range episodes {
+		if ep.EndedAt.After(modTime) {
+			modTime = *ep.EndedAt
+		}
+		feed.Channel.Items = append(feed.Channel.Items, feedItem(base, ep))
+	}
+	if !modTime.IsZero() {
+		feed.Channel.LastBuildDate = modTime.Format(time.RFC1123Z)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "feed.xml", modTime, bytes.NewReader(buf.Bytes()))
+	return nil
+}
+
+func feedItem(base string, ep radio.Episode) rssItem {
+	item := rssItem{
+		Title:   fmt.Sprintf("%s, %s", ep.Channel, ep.StartedAt.Local().Format("Mon 2 Jan 2006 15:04")),
+		GUID:    rssGUID{Value: ep.ID},
+		PubDate: ep.StartedAt.Format(time.RFC1123Z),
+		Enclosure: rssEnclosure{
+			URL:    base + "/radio/recordings/" + ep.ID + ".mp3",
+			Length: ep.Size,
+			Type:   "audio/mpeg",
+		},
+		Duration: clock(ep.Duration),
+	}
+
+	var tracklist []string
+	if len(ep.Cues) > 0 {
+		item.Chapters = &rssChapters{Version: "1.2"}
+	}
+	for _, cue := range ep.Cues {
+		at := time.Duration(cue.Offset * float64(time.Second))
+		title := strings.TrimSuffix(cue.Track, filepath.Ext(cue.Track))
+		item.Chapters.Chapters = append(item.Chapters.Chapters, rssChapter{
+			Start: fmt.Sprintf("%s.%03d", clock(at), at.Milliseconds()%1000),
+			Title: title,
+		})
+		tracklist = append(tracklist, clock(at)+" "+title)
+	}
+	item.Description = "Recorded from " + ep.Channel + "."
+	if len(tracklist) > 0 {
+		item.Description += "\n\n" + strings.Join(tracklist, "\n")
+	}
+
+	return item
+}
+
+// clock formats d as HH:MM:SS.
+func clock(d time.Duration) string {
+	s := int64(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// baseURL returns the public URL of the radio server, used for absolute links.
+// Without a configured URL it is taken from the request.
+func (h *APIHandler) baseURL(r *http.Request) string {
+	if u := *h.publicURL.Load(); u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
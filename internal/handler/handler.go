@@ -3,22 +3,45 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync/atomic"
 
+	"github.com/Pertsaa/go-radio/internal/middleware"
 	"github.com/Pertsaa/go-radio/internal/radio"
 )
 
 type APIHandler struct {
-	ctx   context.Context
-	radio *radio.Radio
+	ctx         context.Context
+	radio       *radio.Radio
+	adminKey    atomic.Pointer[string]
+	publicURL   atomic.Pointer[string]
+	httpMetrics *middleware.HTTPMetrics
 }
 
-func NewAPIHandler(ctx context.Context, radio *radio.Radio) *APIHandler {
-	return &APIHandler{
-		ctx:   ctx,
-		radio: radio,
+func NewAPIHandler(ctx context.Context, radio *radio.Radio, adminKey string, httpMetrics *middleware.HTTPMetrics) *APIHandler {
+	h := &APIHandler{
+		ctx:         ctx,
+		radio:       radio,
+		httpMetrics: httpMetrics,
 	}
+	h.SetAdminKey(adminKey)
+	h.SetPublicURL("")
+	return h
+}
+
+func (h *APIHandler) SetAdminKey(key string) {
+	h.adminKey.Store(&key)
+}
+
+// SetPublicURL sets the base URL used for absolute links, such as in podcast
+// feeds. When empty, links point at the host of the request.
+func (h *APIHandler) SetPublicURL(u string) {
+	h.publicURL.Store(&u)
 }
 
 type AppHandler struct {
@@ -61,14 +84,109 @@ func NewAPIError(code int, message any) APIError {
 
 type APIFunc func(w http.ResponseWriter, r *http.Request) error
 
+// Make adapts an APIFunc to an http.HandlerFunc. Returned errors and panics
+// are logged and turned into JSON error responses, unless the handler has
+// already started writing its response.
 func Make(h APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := h(w, r); err != nil {
-			if apiErr, ok := err.(APIError); ok {
-				writeJSON(w, apiErr.Code, apiErr)
+		tw := &trackingWriter{ResponseWriter: w}
+
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				slog.ErrorContext(r.Context(), "handler panicked",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", p,
+					"stack", string(debug.Stack()),
+				)
+				if !tw.started {
+					writeJSON(tw, http.StatusInternalServerError, NewAPIError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)))
+				}
 			}
+		}()
+
+		err := h(tw, r)
+		if err == nil {
+			return
 		}
+
+		apiErr := toAPIError(err)
+
+		logger := slog.With("method", r.Method, "path", r.URL.Path, "status", apiErr.Code, "error", err)
+		switch {
+		case tw.started || r.Context().Err() != nil:
+			// The client is gone or the response is underway, so the status
+			// can no longer be changed.
+			logger.DebugContext(r.Context(), "request ended with error after response started")
+			return
+		case apiErr.Code == http.StatusInternalServerError:
+			logger.ErrorContext(r.Context(), "request failed")
+		case apiErr.Code > http.StatusInternalServerError:
+			logger.WarnContext(r.Context(), "request rejected")
+		default:
+			logger.InfoContext(r.Context(), "request rejected")
+		}
+
+		if errors.Is(err, radio.ErrCapacityReached) {
+			tw.Header().Set("Retry-After", strconv.Itoa(listenerRetryAfter))
+		}
+
+		writeJSON(tw, apiErr.Code, apiErr)
+	}
+}
+
+// toAPIError maps errors to API errors. Unknown errors become a generic 500
+// so internal details are not leaked to clients.
+func toAPIError(err error) APIError {
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
 	}
+
+	switch {
+	case errors.Is(err, radio.ErrChannelNotFound), errors.Is(err, radio.ErrListenerNotFound),
+		errors.Is(err, radio.ErrRecordingNotFound), errors.Is(err, radio.ErrNotRecording),
+		errors.Is(err, radio.ErrArtworkNotFound):
+		return NewAPIError(http.StatusNotFound, err.Error())
+	case errors.Is(err, radio.ErrTimeShiftDisabled):
+		return NewAPIError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, radio.ErrAlreadyRecording):
+		return NewAPIError(http.StatusConflict, err.Error())
+	case errors.Is(err, radio.ErrChannelOffline), errors.Is(err, radio.ErrCapacityReached):
+		return NewAPIError(http.StatusServiceUnavailable, err.Error())
+	}
+
+	return NewAPIError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// trackingWriter records whether the response has been started.
+type trackingWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *trackingWriter) WriteHeader(statusCode int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackingWriter) Flush() {
+	w.started = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 func writeJSON(w http.ResponseWriter, code int, data any) error {
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pertsaa/go-radio/internal/radio"
+)
+
+func TestToAPIError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want APIError
+	}{
+		{
+			name: "api error",
+			err:  NewAPIError(http.StatusBadRequest, "bad offset"),
+			want: NewAPIError(http.StatusBadRequest, "bad offset"),
+		},
+		{
+			name: "wrapped api error",
+			err:  fmt.Errorf("parse: %w", NewAPIError(http.StatusBadRequest, "bad offset")),
+			want: NewAPIError(http.StatusBadRequest, "bad offset"),
+		},
+		{
+			name: "channel not found",
+			err:  fmt.Errorf("stats: %w", radio.ErrChannelNotFound),
+			want: NewAPIError(http.StatusNotFound, "stats: "+radio.ErrChannelNotFound.Error()),
+		},
+		{
+			name: "channel offline",
+			err:  radio.ErrChannelOffline,
+			want: NewAPIError(http.StatusServiceUnavailable, radio.ErrChannelOffline.Error()),
+		},
+		{
+			name: "listener limit",
+			err:  &radio.LimitError{Scope: radio.LimitScopeServer, Limit: 10},
+			want: NewAPIError(http.StatusServiceUnavailable, (&radio.LimitError{Scope: radio.LimitScopeServer, Limit: 10}).Error()),
+		},
+		{
+			name: "internal error",
+			err:  errors.New("open /srv/radio/jazz/a.mp3: permission denied"),
+			want: NewAPIError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toAPIError(tt.err); got != tt.want {
+				t.Errorf("toAPIError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name       string
+		fn         APIFunc
+		code       int
+		retryAfter string
+		message    string
+	}{
+		{
+			name: "success",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				return writeJSON(w, http.StatusCreated, "ok")
+			},
+			code: http.StatusCreated,
+		},
+		{
+			name: "api error",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				return NewAPIError(http.StatusBadRequest, "bad offset")
+			},
+			code:    http.StatusBadRequest,
+			message: "bad offset",
+		},
+		{
+			name: "listener limit",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("join: %w", &radio.LimitError{Scope: radio.LimitScopeChannel, Limit: 2})
+			},
+			code:       http.StatusServiceUnavailable,
+			retryAfter: "30",
+			message:    "join: " + (&radio.LimitError{Scope: radio.LimitScopeChannel, Limit: 2}).Error(),
+		},
+		{
+			name: "channel offline",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				return radio.ErrChannelOffline
+			},
+			code:    http.StatusServiceUnavailable,
+			message: radio.ErrChannelOffline.Error(),
+		},
+		{
+			name: "internal error",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("disk on fire")
+			},
+			code:    http.StatusInternalServerError,
+			message: http.StatusText(http.StatusInternalServerError),
+		},
+		{
+			name: "panic",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				panic("boom")
+			},
+			code:    http.StatusInternalServerError,
+			message: http.StatusText(http.StatusInternalServerError),
+		},
+		{
+			name: "error after response started",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusOK)
+				return radio.ErrChannelOffline
+			},
+			code: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Make(tt.fn)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.code {
+				t.Errorf("status = %d, want %d", w.Code, tt.code)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if tt.message == "" {
+				return
+			}
+			var body APIError
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if body.Code != tt.code || body.Message != tt.message {
+				t.Errorf("body = %+v, want code %d and message %q", body, tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestMakeRepanicsAbort(t *testing.T) {
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want %v", p, http.ErrAbortHandler)
+		}
+	}()
+	Make(func(w http.ResponseWriter, r *http.Request) error {
+		panic(http.ErrAbortHandler)
+	})(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
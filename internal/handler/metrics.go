@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/Pertsaa/go-radio/internal/metrics"
+)
+
+func (h *APIHandler) MetricsHandler(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", metrics.ContentType)
+
+	mw := metrics.NewWriter(w)
+	channels := h.radio.Metrics()
+
+	family := func(typ string) func(name, help string, value func(i int) float64) {
+		return func(name, help string, value func(i int) float64) {
+			mw.Family(name, typ, help)
+			for i, c := range channels {
+				mw.Sample(name, value(i), metrics.L("channel", c.ChannelName))
+			}
+		}
+	}
+	gauge, counter := family("gauge"), family("counter")
+
+	gauge("radio_channel_on_air", "Whether the channel broadcaster is running.", func(i int) float64 {
+		if channels[i].OnAir {
+			return 1
+		}
+		return 0
+	})
+	gauge("radio_channel_idle", "Whether the channel is suspended for lack of listeners.", func(i int) float64 {
+		if channels[i].Idle {
+			return 1
+		}
+		return 0
+	})
+	gauge("radio_listeners", "Current number of listeners.", func(i int) float64 {
+		return float64(channels[i].Listeners)
+	})
+	counter("radio_bytes_streamed_total", "Total bytes written to listeners.", func(i int) float64 {
+		return float64(channels[i].BytesSent)
+	})
+	counter("radio_chunks_dropped_total", "Total chunks dropped because a listener queue was full.", func(i int) float64 {
+		return float64(channels[i].ChunksDropped)
+	})
+	gauge("radio_broadcaster_lag_seconds", "How far the broadcaster is behind the wall clock.", func(i int) float64 {
+		return channels[i].BroadcasterLag.Seconds()
+	})
+	counter("radio_track_changes_total", "Total number of tracks started.", func(i int) float64 {
+		return float64(channels[i].TrackChanges)
+	})
+	counter("radio_file_open_errors_total", "Total errors opening audio files.", func(i int) float64 {
+		return float64(channels[i].OpenErrors)
+	})
+	counter("radio_file_read_errors_total", "Total errors reading audio files.", func(i int) float64 {
+		return float64(channels[i].ReadErrors)
+	})
+
+	counter("radio_channel_restarts_total", "Total number of broadcast loop restarts by the supervisor.", func(i int) float64 {
+		return float64(channels[i].Restarts)
+	})
+	gauge("radio_quarantined_tracks", "Number of tracks skipped because they could not be played.", func(i int) float64 {
+		return float64(channels[i].Quarantined)
+	})
+
+	mw.Family("radio_listener_disconnects_total", "counter", "Total listener sessions ended, by reason.")
+	for _, c := range channels {
+		for _, reason := range slices.Sorted(maps.Keys(c.Disconnects)) {
+			mw.Sample("radio_listener_disconnects_total", float64(c.Disconnects[reason]),
+				metrics.L("channel", c.ChannelName), metrics.L("reason", string(reason)))
+		}
+	}
+
+	if h.httpMetrics != nil {
+		h.httpMetrics.WritePrometheus(mw)
+	}
+
+	metrics.WriteProcess(mw)
+
+	return mw.Err()
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/radio"
+)
+
+// RadioRecordingListHandler lists recordings, optionally of one channel.
+func (h *APIHandler) RadioRecordingListHandler(w http.ResponseWriter, r *http.Request) error {
+	recordings, err := h.radio.Recordings(r.URL.Query().Get("channel"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, recordings)
+}
+
+// RadioRecordingHandler serves a recording's audio. Range requests are
+// supported so players can seek. The ID may carry an .mp3 extension, which
+// podcast apps expect on enclosure URLs. Recordings play inline unless
+// ?download is given, which asks the browser to save the file instead.
+func (h *APIHandler) RadioRecordingHandler(w http.ResponseWriter, r *http.Request) error {
+	file, rec, err := h.radio.OpenRecording(strings.TrimSuffix(r.PathValue("recordingID"), ".mp3"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	modTime := rec.StartedAt
+	if rec.EndedAt != nil {
+		modTime = *rec.EndedAt
+	}
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	disposition := "inline"
+	if r.URL.Query().Has("download") {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition+`; filename="`+rec.ID+`.mp3"`)
+	http.ServeContent(w, r, rec.ID+".mp3", modTime, file)
+	return nil
+}
+
+// RadioRecordingCuesHandler returns a recording along with its cue list.
+func (h *APIHandler) RadioRecordingCuesHandler(w http.ResponseWriter, r *http.Request) error {
+	rec, err := h.radio.Recording(r.PathValue("recordingID"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rec)
+}
+
+// RadioRecordingStartHandler starts a manual recording of a channel, for the
+// optional duration or until it is stopped. It requires the admin key.
+func (h *APIHandler) RadioRecordingStartHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	var until time.Time
+	if v := r.URL.Query().Get("duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return NewAPIError(http.StatusBadRequest, "duration must be a positive duration such as 2h")
+		}
+		until = time.Now().Add(d)
+	}
+
+	rec, err := h.radio.StartRecording(r.PathValue("channelID"), radio.RecordingManual, until)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, rec)
+}
+
+// RadioRecordingStopHandler stops a channel's manual recording. It requires
+// the admin key.
+func (h *APIHandler) RadioRecordingStopHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	rec, err := h.radio.StopRecording(r.PathValue("channelID"), radio.RecordingManual)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rec)
+}
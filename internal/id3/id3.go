@@ -0,0 +1,354 @@
+// Package id3 reads the text and picture frames of ID3v2 tags, versions 2.2
+// to 2.4, along with trailing ID3v1 tags.
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxTagSize bounds how much of a file is read as a tag.
+const maxTagSize = 16 << 20
+
+var ErrNoTag = errors.New("no ID3 tag")
+
+// PictureFrontCover is the APIC picture type of a front cover.
+const PictureFrontCover = 3
+
+// Tag holds the fields of a tag that the radio uses.
+type Tag struct {
+	Title  string
+	Artist string
+	Album  string
+	Year   string
+	Genre  string
+	Track  string
+	// Pictures are the attached pictures in tag order.
+	Pictures []Picture
+}
+
+type Picture struct {
+	MIMEType    string
+	Type        byte
+	Description string
+	Data        []byte
+}
+
+// Cover returns the front cover, or the first picture if none is marked as
+// such.
+func (t *Tag) Cover() (Picture, bool) {
+	for _, p := range t.Pictures {
+		if p.Type == PictureFrontCover {
+			return p, true
+		}
+	}
+	if len(t.Pictures) > 0 {
+		return t.Pictures[0], true
+	}
+	return Picture{}, false
+}
+
+// Read parses the ID3v2 tag at the start of r. It returns ErrNoTag when
+// there is none.
+func Read(r io.Reader) (*Tag, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNoTag
+		}
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, []byte("ID3")) || header[3] < 2 || header[3] > 4 {
+		return nil, ErrNoTag
+	}
+
+	version, flags := header[3], header[5]
+	size := syncsafe(header[6:10])
+	if size > maxTagSize {
+		return nil, errors.New("ID3 tag too large")
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	if flags&0x80 != 0 && version < 4 {
+		data = unsynchronise(data)
+	}
+	if flags&0x40 != 0 && version > 2 {
+		data = skipExtendedHeader(data, version)
+	}
+
+	tag := &Tag{}
+	for len(data) > 0 {
+		id, body, rest, ok := nextFrame(data, version)
+		if !ok {
+			break
+		}
+		data = rest
+		tag.set(id, body)
+	}
+	return tag, nil
+}
+
+// ReadV1 parses the 128-byte ID3v1 tag at the end of r.
+func ReadV1(r io.ReadSeeker) (*Tag, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return nil, ErrNoTag
+	}
+	b := make([]byte, 128)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, []byte("TAG")) {
+		return nil, ErrNoTag
+	}
+
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return strings.TrimSpace(latin1(b))
+	}
+	tag := &Tag{
+		Title:  field(b[3:33]),
+		Artist: field(b[33:63]),
+		Album:  field(b[63:93]),
+		Year:   field(b[93:97]),
+	}
+	// ID3v1.1 keeps the track number in the last byte of the comment.
+	if b[125] == 0 && b[126] != 0 {
+		tag.Track = strconv.Itoa(int(b[126]))
+	}
+	return tag, nil
+}
+
+// nextFrame splits the first frame off data. Frames that are compressed or
+// encrypted are returned with a nil body.
+func nextFrame(data []byte, version byte) (id string, body, rest []byte, ok bool) {
+	if version == 2 {
+		if len(data) < 6 || data[0] == 0 {
+			return "", nil, nil, false
+		}
+		size := int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		if 6+size > len(data) {
+			return "", nil, nil, false
+		}
+		return string(data[:3]), data[6 : 6+size], data[6+size:], true
+	}
+
+	if len(data) < 10 || data[0] == 0 {
+		return "", nil, nil, false
+	}
+	var size int
+	if version == 4 {
+		size = syncsafe(data[4:8])
+	} else {
+		size = int(binary.BigEndian.Uint32(data[4:8]))
+	}
+	if size < 0 || 10+size > len(data) {
+		return "", nil, nil, false
+	}
+	id = string(data[:4])
+	body = data[10 : 10+size]
+	rest = data[10+size:]
+
+	format := data[9]
+	if version == 3 {
+		if format&0xc0 != 0 {
+			// Compressed or encrypted.
+			return id, nil, rest, true
+		}
+		if format&0x20 != 0 && len(body) > 0 {
+			body = body[1:] // grouping identity
+		}
+		return id, body, rest, true
+	}
+
+	if format&0x0c != 0 {
+		return id, nil, rest, true
+	}
+	if format&0x40 != 0 && len(body) > 0 {
+		body = body[1:]
+	}
+	if format&0x01 != 0 && len(body) >= 4 {
+		body = body[4:] // data length indicator
+	}
+	if format&0x02 != 0 {
+		body = unsynchronise(body)
+	}
+	return id, body, rest, true
+}
+
+func (t *Tag) set(id string, body []byte) {
+	if body == nil {
+		return
+	}
+	switch id {
+	case "TIT2", "TT2":
+		t.Title = text(body)
+	case "TPE1", "TP1":
+		t.Artist = text(body)
+	case "TALB", "TAL":
+		t.Album = text(body)
+	case "TYER", "TDRC", "TYE":
+		t.Year = text(body)
+	case "TCON", "TCO":
+		t.Genre = text(body)
+	case "TRCK", "TRK":
+		t.Track = text(body)
+	case "APIC":
+		if p, ok := apic(body); ok {
+			t.Pictures = append(t.Pictures, p)
+		}
+	case "PIC":
+		if p, ok := pic(body); ok {
+			t.Pictures = append(t.Pictures, p)
+		}
+	}
+}
+
+// text decodes a text frame. Multiple values are joined with " / ".
+func text(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	values := strings.Split(decode(body[0], body[1:]), "\x00")
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return strings.Join(out, " / ")
+}
+
+func apic(body []byte) (Picture, bool) {
+	if len(body) < 2 {
+		return Picture{}, false
+	}
+	enc := body[0]
+	i := bytes.IndexByte(body[1:], 0)
+	if i < 0 {
+		return Picture{}, false
+	}
+	mime := strings.ToLower(latin1(body[1 : 1+i]))
+	rest := body[2+i:]
+	if len(rest) < 1 {
+		return Picture{}, false
+	}
+	typ := rest[0]
+	desc, data, ok := splitTerminated(enc, rest[1:])
+	if !ok {
+		return Picture{}, false
+	}
+	switch mime {
+	case "", "jpg", "image/jpg":
+		mime = "image/jpeg"
+	case "png":
+		mime = "image/png"
+	}
+	return Picture{MIMEType: mime, Type: typ, Description: desc, Data: data}, true
+}
+
+func pic(body []byte) (Picture, bool) {
+	if len(body) < 5 {
+		return Picture{}, false
+	}
+	mime := "image/jpeg"
+	if strings.EqualFold(string(body[1:4]), "PNG") {
+		mime = "image/png"
+	}
+	desc, data, ok := splitTerminated(body[0], body[5:])
+	if !ok {
+		return Picture{}, false
+	}
+	return Picture{MIMEType: mime, Type: body[4], Description: desc, Data: data}, true
+}
+
+// splitTerminated splits a terminated string in the given encoding off b.
+func splitTerminated(enc byte, b []byte) (string, []byte, bool) {
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return decode(enc, b[:i]), b[i+2:], true
+			}
+		}
+		return "", nil, false
+	}
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, false
+	}
+	return decode(enc, b[:i]), b[i+1:], true
+}
+
+// decode converts text in one of the ID3 encodings to UTF-8.
+func decode(enc byte, b []byte) string {
+	switch enc {
+	case 0:
+		return latin1(b)
+	case 1, 2:
+		bigEndian := enc == 2
+		if len(b) >= 2 {
+			switch {
+			case b[0] == 0xff && b[1] == 0xfe:
+				bigEndian, b = false, b[2:]
+			case b[0] == 0xfe && b[1] == 0xff:
+				bigEndian, b = true, b[2:]
+			}
+		}
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			if bigEndian {
+				u[i] = binary.BigEndian.Uint16(b[2*i:])
+			} else {
+				u[i] = binary.LittleEndian.Uint16(b[2*i:])
+			}
+		}
+		return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+	default:
+		return strings.TrimRight(string(b), "\x00")
+	}
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// unsynchronise reverses the unsynchronisation scheme, which inserts a zero
+// byte after every 0xff.
+func unsynchronise(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+func skipExtendedHeader(data []byte, version byte) []byte {
+	if len(data) < 4 {
+		return nil
+	}
+	var size int
+	if version == 4 {
+		// The size includes itself in v2.4.
+		size = syncsafe(data[:4])
+	} else {
+		size = int(binary.BigEndian.Uint32(data[:4])) + 4
+	}
+	if size > len(data) {
+		return nil
+	}
+	return data[size:]
+}
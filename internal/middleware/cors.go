@@ -2,34 +2,180 @@ package middleware
 
 import (
 	"net/http"
+	"path"
 	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-func CORS(next http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		allowedOrigins := []string{
-			"http://localhost:3000",
-			"http://radio.local:3000",
+// CORSOptions describes a cross-origin policy. Origins are exact
+// ("https://a.com"), wildcard subdomains ("https://*.a.com") or "*" for any
+// origin.
+type CORSOptions struct {
+	Origins          []string
+	AllowCredentials bool
+	Methods          []string
+	Headers          []string
+	ExposeHeaders    []string
+	MaxAge           time.Duration
+}
+
+// CORSRoute applies its own options to request paths matching Path, which
+// may contain path.Match wildcards.
+type CORSRoute struct {
+	Path    string
+	Options CORSOptions
+}
+
+type corsRules struct {
+	defaults CORSOptions
+	routes   []CORSRoute
+}
+
+// CORSPolicy selects a policy by request path and applies it. The rules can
+// be replaced at runtime.
+type CORSPolicy struct {
+	rules atomic.Pointer[corsRules]
+}
+
+func NewCORSPolicy(defaults CORSOptions, routes []CORSRoute) *CORSPolicy {
+	p := &CORSPolicy{}
+	p.Set(defaults, routes)
+	return p
+}
+
+func (p *CORSPolicy) Set(defaults CORSOptions, routes []CORSRoute) {
+	p.rules.Store(&corsRules{defaults: defaults, routes: slices.Clone(routes)})
+}
+
+func (p *CORSPolicy) options(r *http.Request) CORSOptions {
+	rules := p.rules.Load()
+	for _, route := range rules.routes {
+		if ok, _ := path.Match(route.Path, r.URL.Path); ok {
+			return route.Options
 		}
+	}
+	return rules.defaults
+}
 
+func (p *CORSPolicy) Middleware(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts := p.options(r)
 		origin := r.Header.Get("Origin")
+		isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		w.Header().Add("Vary", "Origin")
+		if isPreflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
 
-		isAllowed := slices.Contains(allowedOrigins, origin)
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		if isAllowed {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
+		if !opts.allowsOrigin(origin) {
+			if isPreflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Methods", "QUERY, GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Expose-Headers", "Authorization")
+		if isPreflight {
+			if !opts.allowsMethod(r.Header.Get("Access-Control-Request-Method")) ||
+				!opts.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+			opts.setOriginHeaders(w, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(opts.Methods, ", "))
+			if len(opts.Headers) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(opts.Headers, ", "))
+			}
+			if opts.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
+		opts.setOriginHeaders(w, origin)
+		if len(opts.ExposeHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(opts.ExposeHeaders, ", "))
+		}
+
 		next.ServeHTTP(w, r)
 	}
 }
+
+func (o CORSOptions) setOriginHeaders(w http.ResponseWriter, origin string) {
+	// A literal "*" is not allowed together with credentials, so the origin
+	// is reflected instead.
+	if slices.Contains(o.Origins, "*") && !o.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if o.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (o CORSOptions) allowsOrigin(origin string) bool {
+	for _, pattern := range o.Origins {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CORSOptions) allowsMethod(method string) bool {
+	return slices.ContainsFunc(o.Methods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	})
+}
+
+func (o CORSOptions) allowsHeaders(requested string) bool {
+	for header := range strings.SplitSeq(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(o.Headers, func(h string) bool {
+			return strings.EqualFold(h, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchOrigin matches an origin against an exact origin, "*" or a pattern
+// with a single wildcard standing for one or more subdomain labels.
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || strings.EqualFold(pattern, origin) {
+		return true
+	}
+
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok || len(origin) <= len(prefix)+len(suffix) {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	if !strings.HasPrefix(origin, strings.ToLower(prefix)) || !strings.HasSuffix(origin, strings.ToLower(suffix)) {
+		return false
+	}
+
+	labels := origin[len(prefix) : len(origin)-len(suffix)]
+	if strings.HasPrefix(labels, ".") || strings.HasSuffix(labels, ".") {
+		return false
+	}
+	return strings.Trim(labels, "abcdefghijklmnopqrstuvwxyz0123456789-.") == ""
+}
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"*", "https://anything.example", true},
+		{"https://a.com", "https://a.com", true},
+		{"https://a.com", "HTTPS://A.COM", true},
+		{"https://a.com", "http://a.com", false},
+		{"https://a.com", "https://a.com:8443", false},
+		{"https://*.a.com", "https://b.a.com", true},
+		{"https://*.a.com", "https://c.b.a.com", true},
+		{"https://*.a.com", "https://B.A.com", true},
+		{"https://*.a.com", "https://a.com", false},
+		{"https://*.a.com", "https://.a.com", false},
+		{"https://*.a.com", "https://b..a.com", false},
+		{"https://*.a.com", "https://b.a.com.evil.com", false},
+		{"https://*.a.com", "https://evil.com/.a.com", false},
+		{"https://*.a.com", "https://evil_b.a.com", false},
+		{"https://*.a.com", "http://b.a.com", false},
+		{"https://*.a.com:8443", "https://b.a.com:8443", true},
+	}
+	for _, tt := range tests {
+		if got := matchOrigin(tt.pattern, tt.origin); got != tt.want {
+			t.Errorf("matchOrigin(%q, %q) = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSPolicy(t *testing.T) {
+	defaults := CORSOptions{
+		Origins:          []string{"https://app.example", "https://*.example.org"},
+		AllowCredentials: true,
+		Methods:          []string{"GET", "POST"},
+		Headers:          []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-Request-ID"},
+		MaxAge:           10 * time.Minute,
+	}
+	routes := []CORSRoute{
+		{
+			Path:    "/radio/channels/*/stream",
+			Options: CORSOptions{Origins: []string{"*"}, Methods: []string{"GET"}, MaxAge: time.Hour},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+		want    map[string]string
+	}{
+		{
+			name:   "same origin",
+			method: http.MethodGet,
+			path:   "/radio/channels",
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "allowed origin",
+			method:  http.MethodGet,
+			path:    "/radio/channels",
+			headers: map[string]string{"Origin": "https://app.example"},
+			status:  http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-ID",
+			},
+		},
+		{
+			name:    "disallowed origin",
+			method:  http.MethodGet,
+			path:    "/radio/channels",
+			headers: map[string]string{"Origin": "https://evil.example"},
+			status:  http.StatusOK,
+			want:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight",
+			method: http.MethodOptions,
+			path:   "/radio/channels",
+			headers: map[string]string{
+				"Origin":                         "https://b.example.org",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "content-type, authorization",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://b.example.org",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+				"Access-Control-Max-Age":           "600",
+			},
+		},
+		{
+			name:   "preflight from disallowed origin",
+			method: http.MethodOptions,
+			path:   "/radio/channels",
+			headers: map[string]string{
+				"Origin":                        "https://evil.example",
+				"Access-Control-Request-Method": "GET",
+			},
+			status: http.StatusForbidden,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight with disallowed method",
+			method: http.MethodOptions,
+			path:   "/radio/channels",
+			headers: map[string]string{
+				"Origin":                        "https://app.example",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			status: http.StatusForbidden,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight with disallowed header",
+			method: http.MethodOptions,
+			path:   "/radio/channels",
+			headers: map[string]string{
+				"Origin":                         "https://app.example",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Custom",
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:    "options without preflight",
+			method:  http.MethodOptions,
+			path:    "/radio/channels",
+			headers: map[string]string{"Origin": "https://app.example"},
+			status:  http.StatusOK,
+			want:    map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:    "route with any origin",
+			method:  http.MethodGet,
+			path:    "/radio/channels/jazz/stream",
+			headers: map[string]string{"Origin": "https://elsewhere.example"},
+			status:  http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:   "route preflight",
+			method: http.MethodOptions,
+			path:   "/radio/channels/jazz/stream",
+			headers: map[string]string{
+				"Origin":                        "https://elsewhere.example",
+				"Access-Control-Request-Method": "GET",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+	}
+
+	policy := NewCORSPolicy(defaults, routes)
+	handler := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			for key, want := range tt.want {
+				if got := w.Header().Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+			if vary := w.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Origin" {
+				t.Errorf("Vary = %q, want Origin first", vary)
+			}
+		})
+	}
+}
+
+func TestCORSPolicySet(t *testing.T) {
+	policy := NewCORSPolicy(CORSOptions{Origins: []string{"https://old.example"}}, nil)
+	handler := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	policy.Set(CORSOptions{Origins: []string{"https://new.example"}}, nil)
+
+	for origin, want := range map[string]string{
+		"https://old.example": "",
+		"https://new.example": "https://new.example",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("origin %s: Access-Control-Allow-Origin = %q, want %q", origin, got, want)
+		}
+	}
+}
@@ -1,32 +1,25 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
 
-type wrappedWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (w *wrappedWriter) WriteHeader(statusCode int) {
-	w.ResponseWriter.WriteHeader(statusCode)
-	w.statusCode = statusCode
-}
-
 func Log(next http.Handler) http.HandlerFunc {
 	return (func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		wrapped := &wrappedWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
-		}
+		wrapped := newWrappedWriter(w)
 
 		next.ServeHTTP(wrapped, r)
 
-		log.Println(wrapped.statusCode, r.Method, r.URL.Path, time.Since(start))
+		slog.InfoContext(r.Context(), "request",
+			"status", wrapped.statusCode,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"bytes", wrapped.bytes,
+			"ttfb", wrapped.timeToFirstByte(),
+			"duration", time.Since(wrapped.start),
+			"hijacked", wrapped.hijacked,
+		)
 	})
 }
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/metrics"
+)
+
+type requestKey struct {
+	method string
+	route  string
+	code   string
+}
+
+// HTTPMetrics counts requests and their durations by method, route pattern
+// and status code.
+type HTTPMetrics struct {
+	mu         sync.Mutex
+	histograms map[requestKey]*metrics.Histogram
+}
+
+func NewHTTPMetrics() *HTTPMetrics {
+	return &HTTPMetrics{histograms: make(map[requestKey]*metrics.Histogram)}
+}
+
+func (m *HTTPMetrics) Middleware(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wrapped := newWrappedWriter(w)
+
+		next.ServeHTTP(wrapped, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.observe(requestKey{
+			method: methodLabel(r.Method),
+			route:  route,
+			code:   strconv.Itoa(wrapped.statusCode),
+		}, time.Since(wrapped.start))
+	}
+}
+
+// methodLabel returns the method of a request for use as a label. Clients can
+// send any method, so unknown ones are grouped to keep the series bounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+func (m *HTTPMetrics) observe(key requestKey, d time.Duration) {
+	m.mu.Lock()
+	h, ok := m.histograms[key]
+	if !ok {
+		h = metrics.NewHistogram(metrics.DefaultBuckets)
+		m.histograms[key] = h
+	}
+	m.mu.Unlock()
+
+	h.Observe(d.Seconds())
+}
+
+func (m *HTTPMetrics) WritePrometheus(w *metrics.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(m.histograms))
+	for key := range m.histograms {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		return strings.Compare(a.method+a.route+a.code, b.method+b.route+b.code)
+	})
+
+	w.Family("radio_http_requests_total", "counter", "Total number of HTTP requests.")
+	for _, key := range keys {
+		w.Sample("radio_http_requests_total", float64(m.histograms[key].Count()), key.labels()...)
+	}
+
+	w.Family("radio_http_request_duration_seconds", "histogram", "Duration of HTTP requests, including streams.")
+	for _, key := range keys {
+		m.histograms[key].Write(w, "radio_http_request_duration_seconds", key.labels()...)
+	}
+}
+
+func (k requestKey) labels() []metrics.Label {
+	return []metrics.Label{
+		metrics.L("method", k.method),
+		metrics.L("route", k.route),
+		metrics.L("code", k.code),
+	}
+}
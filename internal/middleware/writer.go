@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// wrappedWriter records the status code, bytes written and time to first
+// byte of a response. It passes Flush and Hijack through to the underlying
+// writer and supports http.ResponseController through Unwrap, so it is safe
+// to put in front of streaming and upgraded connections.
+type wrappedWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	statusCode  int
+	bytes       int64
+	firstByteAt time.Time
+	hijacked    bool
+}
+
+func newWrappedWriter(w http.ResponseWriter) *wrappedWriter {
+	return &wrappedWriter{
+		ResponseWriter: w,
+		start:          time.Now(),
+		statusCode:     http.StatusOK,
+	}
+}
+
+func (w *wrappedWriter) markFirstByte() {
+	if w.firstByteAt.IsZero() {
+		w.firstByteAt = time.Now()
+	}
+}
+
+func (w *wrappedWriter) WriteHeader(statusCode int) {
+	w.markFirstByte()
+	w.ResponseWriter.WriteHeader(statusCode)
+	w.statusCode = statusCode
+}
+
+func (w *wrappedWriter) Write(b []byte) (int, error) {
+	w.markFirstByte()
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *wrappedWriter) Flush() {
+	w.FlushError()
+}
+
+func (w *wrappedWriter) FlushError() error {
+	w.markFirstByte()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *wrappedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+		w.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// timeToFirstByte returns zero if nothing has been written.
+func (w *wrappedWriter) timeToFirstByte() time.Duration {
+	if w.firstByteAt.IsZero() {
+		return 0
+	}
+	return w.firstByteAt.Sub(w.start)
+}
@@ -0,0 +1,160 @@
+// Package mp3 parses MPEG audio frame headers.
+package mp3
+
+import "errors"
+
+// HeaderSize is the length of an MPEG audio frame header.
+const HeaderSize = 4
+
+var ErrInvalidHeader = errors.New("invalid mpeg audio frame header")
+
+type Version int
+
+const (
+	MPEG25 Version = iota
+	_
+	MPEG2
+	MPEG1
+)
+
+type Layer int
+
+const (
+	_ Layer = iota
+	Layer3
+	Layer2
+	Layer1
+)
+
+// Header is a decoded MPEG audio frame header.
+type Header struct {
+	Version    Version
+	Layer      Layer
+	Bitrate    int // bits per second
+	SampleRate int // Hz
+	Padding    bool
+	Channels   int
+}
+
+// bitrates in kbit/s indexed by [MPEG1 ? 0 : 1][layer index][bitrate index].
+var bitrates = [2][4][16]int{
+	{
+		{},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	},
+	{
+		{},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	},
+}
+
+var sampleRates = [4][3]int{
+	MPEG25: {11025, 12000, 8000},
+	MPEG2:  {22050, 24000, 16000},
+	MPEG1:  {44100, 48000, 32000},
+}
+
+// ParseHeader decodes the frame header at the start of b.
+func ParseHeader(b []byte) (Header, error) {
+	if len(b) < HeaderSize || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return Header{}, ErrInvalidHeader
+	}
+
+	version := Version(b[1] >> 3 & 0x03)
+	layer := Layer(b[1] >> 1 & 0x03)
+	bitrateIndex := b[2] >> 4
+	rateIndex := b[2] >> 2 & 0x03
+	if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 0x0F || rateIndex == 0x03 {
+		// Reserved values, and free-format streams which are not supported.
+		return Header{}, ErrInvalidHeader
+	}
+
+	table := 1
+	if version == MPEG1 {
+		table = 0
+	}
+
+	h := Header{
+		Version:    version,
+		Layer:      layer,
+		Bitrate:    bitrates[table][layer][bitrateIndex] * 1000,
+		SampleRate: sampleRates[version][rateIndex],
+		Padding:    b[2]&0x02 != 0,
+		Channels:   2,
+	}
+	if b[3]>>6 == 0x03 {
+		h.Channels = 1
+	}
+	return h, nil
+}
+
+// Samples returns the number of samples per channel in a frame.
+func (h Header) Samples() int {
+	switch {
+	case h.Layer == Layer1:
+		return 384
+	case h.Layer == Layer3 && h.Version != MPEG1:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// FrameSize returns the length of the frame in bytes, header included.
+func (h Header) FrameSize() int {
+	if h.Layer == Layer1 {
+		size := 12 * h.Bitrate / h.SampleRate
+		if h.Padding {
+			size++
+		}
+		return size * 4
+	}
+
+	size := h.Samples() / 8 * h.Bitrate / h.SampleRate
+	if h.Padding {
+		size++
+	}
+	return size
+}
+
+// SideInfoSize returns the length of the Layer III side information that
+// follows the header.
+func (h Header) SideInfoSize() int {
+	switch {
+	case h.Version == MPEG1 && h.Channels == 1:
+		return 17
+	case h.Version == MPEG1:
+		return 32
+	case h.Channels == 1:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// Sync returns the offset of the first frame in b, or -1 if there is none. A
+// frame counts only if the following header, when it lies within b, is valid
+// too, which rules out most false syncs inside audio data.
+func Sync(b []byte) int {
+	for i := 0; i+HeaderSize <= len(b); i++ {
+		if b[i] != 0xFF {
+			continue
+		}
+		h, err := ParseHeader(b[i:])
+		if err != nil {
+			continue
+		}
+		next := i + h.FrameSize()
+		if next+HeaderSize <= len(b) {
+			if _, err := ParseHeader(b[next:]); err != nil {
+				continue
+			}
+		}
+		return i
+	}
+	return -1
+}
@@ -0,0 +1,129 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// frameSize is the length of the frames built by testFrame: MPEG-1 Layer III
+// at 128 kbit/s and 44.1 kHz.
+const frameSize = 417
+
+// testFrame returns a stereo frame filled with fill after the header.
+func testFrame(fill byte) []byte {
+	frame := bytes.Repeat([]byte{fill}, frameSize)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return frame
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    []byte
+		want      Header
+		samples   int
+		frameSize int
+	}{
+		{
+			name:      "mpeg1 layer3",
+			header:    []byte{0xFF, 0xFB, 0x90, 0x00},
+			want:      Header{Version: MPEG1, Layer: Layer3, Bitrate: 128000, SampleRate: 44100, Channels: 2},
+			samples:   1152,
+			frameSize: frameSize,
+		},
+		{
+			name:      "padding",
+			header:    []byte{0xFF, 0xFB, 0x92, 0x00},
+			want:      Header{Version: MPEG1, Layer: Layer3, Bitrate: 128000, SampleRate: 44100, Padding: true, Channels: 2},
+			samples:   1152,
+			frameSize: frameSize + 1,
+		},
+		{
+			name:      "mono",
+			header:    []byte{0xFF, 0xFB, 0x90, 0xC0},
+			want:      Header{Version: MPEG1, Layer: Layer3, Bitrate: 128000, SampleRate: 44100, Channels: 1},
+			samples:   1152,
+			frameSize: frameSize,
+		},
+		{
+			name:      "mpeg2 layer3",
+			header:    []byte{0xFF, 0xF3, 0x90, 0x00},
+			want:      Header{Version: MPEG2, Layer: Layer3, Bitrate: 80000, SampleRate: 22050, Channels: 2},
+			samples:   576,
+			frameSize: 261,
+		},
+		{
+			name:      "mpeg1 layer1",
+			header:    []byte{0xFF, 0xFF, 0x90, 0x00},
+			want:      Header{Version: MPEG1, Layer: Layer1, Bitrate: 288000, SampleRate: 44100, Channels: 2},
+			samples:   384,
+			frameSize: 312,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHeader(tt.header)
+			if err != nil {
+				t.Fatalf("ParseHeader() error = %v", err)
+			}
+			if h != tt.want {
+				t.Errorf("ParseHeader() = %+v, want %+v", h, tt.want)
+			}
+			if got := h.Samples(); got != tt.samples {
+				t.Errorf("Samples() = %d, want %d", got, tt.samples)
+			}
+			if got := h.FrameSize(); got != tt.frameSize {
+				t.Errorf("FrameSize() = %d, want %d", got, tt.frameSize)
+			}
+		})
+	}
+}
+
+func TestParseHeaderInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{name: "short", header: []byte{0xFF, 0xFB, 0x90}},
+		{name: "no sync", header: []byte{0x00, 0xFB, 0x90, 0x00}},
+		{name: "reserved version", header: []byte{0xFF, 0xEB, 0x90, 0x00}},
+		{name: "reserved layer", header: []byte{0xFF, 0xF9, 0x90, 0x00}},
+		{name: "free format", header: []byte{0xFF, 0xFB, 0x00, 0x00}},
+		{name: "bad bitrate", header: []byte{0xFF, 0xFB, 0xF0, 0x00}},
+		{name: "reserved sample rate", header: []byte{0xFF, 0xFB, 0x9C, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseHeader(tt.header); !errors.Is(err, ErrInvalidHeader) {
+				t.Errorf("ParseHeader() error = %v, want %v", err, ErrInvalidHeader)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	frame := testFrame(0x55)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "frame", data: append(testFrame(0), frame...), want: 0},
+		{name: "leading junk", data: append([]byte{1, 2, 3}, append(testFrame(0), frame...)...), want: 3},
+		{name: "false sync", data: append([]byte{0xFF, 0xFB, 0x90, 0x00, 0}, append(testFrame(0), frame...)...), want: 5},
+		{name: "last frame", data: frame[:HeaderSize+10], want: 0},
+		{name: "no frame", data: []byte("no audio here"), want: -1},
+		{name: "empty", data: nil, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sync(tt.data); got != tt.want {
+				t.Errorf("Sync() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package mp3
+
+import "bytes"
+
+// GainStep is the change in volume, in dB, of one step of a Layer III
+// granule's global gain.
+const GainStep = 1.5
+
+// GainSteps converts a gain in dB to the nearest whole number of steps.
+func GainSteps(db float64) int {
+	steps := db / GainStep
+	if steps < 0 {
+		return int(steps - 0.5)
+	}
+	return int(steps + 0.5)
+}
+
+// AdjustGain changes the volume of a Layer III frame without decoding it by
+// adding steps to the global gain of every granule, clamped to the valid
+// range. Frames of other layers are left alone. Protected frames get a new
+// checksum.
+func AdjustGain(frame []byte, steps int) {
+	h, err := ParseHeader(frame)
+	if err != nil || h.Layer != Layer3 || steps == 0 {
+		return
+	}
+
+	protected := frame[1]&0x01 == 0
+	start := HeaderSize
+	if protected {
+		start += 2
+	}
+	if len(frame) < start+h.SideInfoSize() {
+		return
+	}
+	side := frame[start : start+h.SideInfoSize()]
+
+	// Skip main_data_begin, the private bits and scfsi to reach the first
+	// granule, then step over whole granules.
+	var pos, granules, granuleBits int
+	switch {
+	case h.Version == MPEG1 && h.Channels == 1:
+		pos, granules, granuleBits = 9+5+4, 2, 59
+	case h.Version == MPEG1:
+		pos, granules, granuleBits = 9+3+8, 2, 59
+	case h.Channels == 1:
+		pos, granules, granuleBits = 8+1, 1, 63
+	default:
+		pos, granules, granuleBits = 8+2, 1, 63
+	}
+
+	for range granules * h.Channels {
+		// global_gain follows part2_3_length and big_values.
+		at := pos + 12 + 9
+		gain := min(max(int(bits(side, at, 8))+steps, 0), 255)
+		setBits(side, at, 8, uint32(gain))
+		pos += granuleBits
+	}
+
+	if protected {
+		crc := crc16(0xffff, frame[2:4])
+		crc = crc16(crc, side)
+		frame[4], frame[5] = byte(crc>>8), byte(crc)
+	}
+}
+
+// GainFilter applies AdjustGain to a stream that arrives in arbitrary pieces.
+// Bytes that do not make up a whole frame yet are held back until the next
+// call, or until Flush. ID3v2 tags and junk pass through unchanged.
+type GainFilter struct {
+	steps   int
+	pending []byte
+	// skip is how much of an ID3v2 tag is still to pass through.
+	skip int
+}
+
+func NewGainFilter(steps int) *GainFilter {
+	return &GainFilter{steps: steps}
+}
+
+// Apply returns the part of the stream so far that is ready to be sent.
+func (f *GainFilter) Apply(b []byte) []byte {
+	data := append(f.pending, b...)
+	i := 0
+	for i < len(data) {
+		if f.skip > 0 {
+			n := min(f.skip, len(data)-i)
+			f.skip -= n
+			i += n
+			continue
+		}
+
+		rest := data[i:]
+		if len(rest) < HeaderSize || len(rest) < 10 && bytes.HasPrefix(rest, []byte("ID3")) {
+			// Not enough to tell what comes next.
+			break
+		}
+		if bytes.HasPrefix(rest, []byte("ID3")) {
+			f.skip = 10 + (int(rest[6]&0x7f)<<21 | int(rest[7]&0x7f)<<14 | int(rest[8]&0x7f)<<7 | int(rest[9]&0x7f))
+			if rest[5]&0x10 != 0 {
+				f.skip += 10 // footer
+			}
+			continue
+		}
+
+		h, err := ParseHeader(rest)
+		if err != nil {
+			i++
+			continue
+		}
+		size := h.FrameSize()
+		if len(rest) < size {
+			break
+		}
+		AdjustGain(rest[:size], f.steps)
+		i += size
+	}
+
+	f.pending = append([]byte(nil), data[i:]...)
+	return data[:i]
+}
+
+// Flush returns whatever is still held back.
+func (f *GainFilter) Flush() []byte {
+	b := f.pending
+	f.pending = nil
+	return b
+}
+
+// bits reads n bits of b starting at bit pos, most significant first.
+func bits(b []byte, pos, n int) uint32 {
+	var v uint32
+	for i := pos; i < pos+n; i++ {
+		v = v<<1 | uint32(b[i/8]>>(7-i%8)&1)
+	}
+	return v
+}
+
+func setBits(b []byte, pos, n int, v uint32) {
+	for i := pos + n - 1; i >= pos; i-- {
+		mask := byte(1) << (7 - i%8)
+		if v&1 != 0 {
+			b[i/8] |= mask
+		} else {
+			b[i/8] &^= mask
+		}
+		v >>= 1
+	}
+}
+
+// crc16 continues the MPEG audio CRC, polynomial 0x8005, over b.
+func crc16(crc uint16, b []byte) uint16 {
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
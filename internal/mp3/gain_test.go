@@ -0,0 +1,148 @@
+package mp3
+
+import (
+	"bytes"
+	"testing"
+)
+
+// globalGains returns the global gain of every granule of an MPEG-1 stereo
+// frame without CRC.
+func globalGains(frame []byte) []uint32 {
+	side := frame[HeaderSize:]
+	var gains []uint32
+	for i := range 4 {
+		gains = append(gains, bits(side, 20+i*59+21, 8))
+	}
+	return gains
+}
+
+func setGlobalGains(frame []byte, gain uint32) {
+	side := frame[HeaderSize:]
+	for i := range 4 {
+		setBits(side, 20+i*59+21, 8, gain)
+	}
+}
+
+func TestGainSteps(t *testing.T) {
+	tests := []struct {
+		db   float64
+		want int
+	}{
+		{0, 0},
+		{1.5, 1},
+		{-1.5, -1},
+		{6, 4},
+		{-6, -4},
+		{0.7, 0},
+		{0.8, 1},
+		{-0.8, -1},
+	}
+	for _, tt := range tests {
+		if got := GainSteps(tt.db); got != tt.want {
+			t.Errorf("GainSteps(%v) = %d, want %d", tt.db, got, tt.want)
+		}
+	}
+}
+
+func TestAdjustGain(t *testing.T) {
+	tests := []struct {
+		name  string
+		gain  uint32
+		steps int
+		want  uint32
+	}{
+		{name: "louder", gain: 100, steps: 4, want: 104},
+		{name: "quieter", gain: 100, steps: -4, want: 96},
+		{name: "unchanged", gain: 100, steps: 0, want: 100},
+		{name: "clamped high", gain: 250, steps: 10, want: 255},
+		{name: "clamped low", gain: 3, steps: -10, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := testFrame(0)
+			setGlobalGains(frame, tt.gain)
+			before := bytes.Clone(frame)
+
+			AdjustGain(frame, tt.steps)
+
+			for i, gain := range globalGains(frame) {
+				if gain != tt.want {
+					t.Errorf("granule %d gain = %d, want %d", i, gain, tt.want)
+				}
+			}
+			// Only the global gains may change.
+			setGlobalGains(frame, tt.gain)
+			if !bytes.Equal(frame, before) {
+				t.Error("AdjustGain changed more than the global gains")
+			}
+		})
+	}
+}
+
+func TestAdjustGainLeavesOtherLayers(t *testing.T) {
+	// MPEG-1 Layer II, 128 kbit/s, 44.1 kHz.
+	frame := testFrame(0x55)
+	frame[1] = 0xFD
+	before := bytes.Clone(frame)
+
+	AdjustGain(frame, 4)
+
+	if !bytes.Equal(frame, before) {
+		t.Error("AdjustGain changed a Layer II frame")
+	}
+}
+
+func TestAdjustGainChecksum(t *testing.T) {
+	frame := testFrame(0)
+	frame[1] = 0xFA // protected
+	side := frame[HeaderSize+2 : HeaderSize+2+32]
+	for i := range 4 {
+		setBits(side, 20+i*59+21, 8, 100)
+	}
+
+	AdjustGain(frame, 2)
+
+	if got := bits(side, 20+21, 8); got != 102 {
+		t.Errorf("gain = %d, want 102", got)
+	}
+	crc := crc16(crc16(0xffff, frame[2:4]), side)
+	if got := uint16(frame[4])<<8 | uint16(frame[5]); got != crc {
+		t.Errorf("checksum = %#04x, want %#04x", got, crc)
+	}
+}
+
+func TestGainFilter(t *testing.T) {
+	var stream []byte
+	stream = append(stream, id3v2(50)...)
+	for range 3 {
+		frame := testFrame(0)
+		setGlobalGains(frame, 100)
+		stream = append(stream, frame...)
+	}
+
+	// Feed the stream in pieces that split the tag and frames.
+	f := NewGainFilter(2)
+	var out []byte
+	for b := stream; len(b) > 0; {
+		n := min(7, len(b))
+		out = append(out, f.Apply(b[:n])...)
+		b = b[n:]
+	}
+	out = append(out, f.Flush()...)
+
+	if len(out) != len(stream) {
+		t.Fatalf("filtered %d bytes, want %d", len(out), len(stream))
+	}
+	if !bytes.Equal(out[:60], stream[:60]) {
+		t.Error("ID3v2 tag was changed")
+	}
+	for i := range 3 {
+		frame := out[60+i*frameSize : 60+(i+1)*frameSize]
+		for _, gain := range globalGains(frame) {
+			if gain != 102 {
+				t.Errorf("frame %d gain = %d, want 102", i, gain)
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package mp3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+var ErrNoFrames = errors.New("no mpeg audio frames found")
+
+// seekInterval is how many frames apart Scan records seek points, about 26
+// seconds at 44.1 kHz.
+const seekInterval = 1000
+
+// Info describes an MPEG audio stream.
+type Info struct {
+	Duration   time.Duration
+	Frames     int
+	SampleRate int
+	// DataStart and DataEnd delimit the audio frames, excluding tags.
+	DataStart int64
+	DataEnd   int64
+	// index holds every seekInterval-th frame, so Seek can start reading
+	// near the frame it looks for.
+	index []seekPoint
+}
+
+// seekPoint is a frame and the number of samples before it.
+type seekPoint struct {
+	offset  int64
+	samples int64
+}
+
+// ByteRate returns the average number of audio bytes per second.
+func (i Info) ByteRate() float64 {
+	if i.Duration <= 0 {
+		return 0
+	}
+	return float64(i.DataEnd-i.DataStart) / i.Duration.Seconds()
+}
+
+// Scan reads a whole stream and measures its exact duration by counting
+// frames.
+func Scan(r io.Reader) (Info, error) {
+	var info Info
+	var samples int64
+
+	err := walk(r, 0, func(offset int64, h Header, frame []byte) bool {
+		if info.Frames == 0 {
+			info.DataStart = offset
+			info.SampleRate = h.SampleRate
+			if isInfoFrame(h, frame) {
+				// The Xing/Info frame carries no audio.
+				info.DataStart += int64(len(frame))
+				return true
+			}
+		}
+		if info.Frames%seekInterval == 0 {
+			info.index = append(info.index, seekPoint{offset: offset, samples: samples})
+		}
+		info.Frames++
+		samples += int64(h.Samples())
+		info.DataEnd = offset + int64(len(frame))
+		return true
+	})
+	if err != nil {
+		return Info{}, err
+	}
+	if info.Frames == 0 {
+		return Info{}, ErrNoFrames
+	}
+
+	info.Duration = time.Duration(samples * int64(time.Second) / int64(info.SampleRate))
+	return info, nil
+}
+
+// Seek returns the offset of the frame playing at the given time into the
+// stream, along with the exact time that frame starts. Times past the end
+// return the end of the audio data.
+func Seek(r io.Reader, at time.Duration) (offset int64, start time.Duration, err error) {
+	return seek(r, seekPoint{}, true, at)
+}
+
+// Seek is like the package-level Seek for the stream that i describes, but
+// reads only from the closest frame before at that Scan recorded.
+func (i Info) Seek(r io.ReadSeeker, at time.Duration) (offset int64, start time.Duration, err error) {
+	if len(i.index) == 0 {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		return Seek(r, at)
+	}
+
+	n := sort.Search(len(i.index), func(n int) bool {
+		return time.Duration(i.index[n].samples*int64(time.Second)/int64(i.SampleRate)) > at
+	})
+	point := i.index[max(n-1, 0)]
+	if _, err := r.Seek(point.offset, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	return seek(r, point, false, at)
+}
+
+// seek walks the stream from the frame at from, which is the first frame of
+// the stream if first is set.
+func seek(r io.Reader, from seekPoint, first bool, at time.Duration) (offset int64, start time.Duration, err error) {
+	samples := from.samples
+	found := false
+
+	err = walk(r, from.offset, func(frameOffset int64, h Header, frame []byte) bool {
+		if !found {
+			found = true
+			if first && isInfoFrame(h, frame) {
+				return true
+			}
+		}
+		t := time.Duration(samples * int64(time.Second) / int64(h.SampleRate))
+		end := time.Duration((samples + int64(h.Samples())) * int64(time.Second) / int64(h.SampleRate))
+		offset, start = frameOffset, t
+		if at < end {
+			return false
+		}
+		offset, start = frameOffset+int64(len(frame)), end
+		samples += int64(h.Samples())
+		return true
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return 0, 0, ErrNoFrames
+	}
+	return offset, start, nil
+}
+
+// walk calls fn for every frame in the stream, which r reads from the given
+// offset on, until fn returns false. Leading ID3v2 tags, a trailing ID3v1 tag
+// and junk between frames are skipped.
+func walk(r io.Reader, offset int64, fn func(offset int64, h Header, frame []byte) bool) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	skipped, err := skipID3v2(br)
+	if err != nil {
+		return err
+	}
+	offset += skipped
+
+	var junk, total int64
+	for {
+		b, err := br.Peek(HeaderSize)
+		if len(b) < HeaderSize {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if bytes.HasPrefix(b, []byte("TAG")) {
+			break
+		}
+
+		h, herr := ParseHeader(b)
+		if herr == nil {
+			frame, err := br.Peek(h.FrameSize())
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if len(frame) == h.FrameSize() && nextIsFrame(br, h.FrameSize()) {
+				if !fn(offset, h, frame) {
+					return nil
+				}
+				br.Discard(len(frame))
+				offset += int64(len(frame))
+				total += int64(len(frame))
+				continue
+			}
+		}
+
+		br.Discard(1)
+		offset++
+		junk++
+	}
+
+	if junk > total {
+		// Mostly garbage with a few accidental syncs is not an audio stream.
+		return ErrNoFrames
+	}
+	return nil
+}
+
+// nextIsFrame reports whether the bytes after a frame of the given size look
+// like another frame, a tag or the end of the stream.
+func nextIsFrame(br *bufio.Reader, size int) bool {
+	b, _ := br.Peek(size + HeaderSize)
+	if len(b) < size+HeaderSize {
+		return true
+	}
+	next := b[size:]
+	if bytes.HasPrefix(next, []byte("TAG")) || bytes.HasPrefix(next, []byte("ID3")) {
+		return true
+	}
+	_, err := ParseHeader(next)
+	return err == nil
+}
+
+// skipID3v2 discards any ID3v2 tags at the start of the stream and returns
+// how many bytes were skipped.
+func skipID3v2(br *bufio.Reader) (int64, error) {
+	var skipped int64
+	for {
+		b, err := br.Peek(10)
+		if len(b) < 10 || !bytes.HasPrefix(b, []byte("ID3")) {
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+			return skipped, nil
+		}
+
+		size := int64(b[6])<<21 | int64(b[7])<<14 | int64(b[8])<<7 | int64(b[9])
+		size += 10
+		if b[5]&0x10 != 0 {
+			size += 10 // footer
+		}
+		n, err := br.Discard(int(size))
+		skipped += int64(n)
+		if err != nil {
+			if err == io.EOF {
+				return skipped, nil
+			}
+			return 0, err
+		}
+	}
+}
+
+// isInfoFrame reports whether a frame is a Xing, Info or VBRI header frame.
+func isInfoFrame(h Header, frame []byte) bool {
+	if h.Layer != Layer3 {
+		return false
+	}
+	xing := HeaderSize + h.SideInfoSize()
+	if len(frame) >= xing+4 {
+		tag := frame[xing : xing+4]
+		if bytes.Equal(tag, []byte("Xing")) || bytes.Equal(tag, []byte("Info")) {
+			return true
+		}
+	}
+	return len(frame) >= 36+4 && bytes.Equal(frame[36:40], []byte("VBRI"))
+}
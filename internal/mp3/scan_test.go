@@ -0,0 +1,188 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// frames returns how long n test frames play.
+func frames(n int) time.Duration {
+	return time.Duration(int64(n) * 1152 * int64(time.Second) / 44100)
+}
+
+// testStream returns n frames, optionally preceded by a Xing frame.
+func testStream(n int, xing bool) []byte {
+	var b []byte
+	if xing {
+		frame := testFrame(0)
+		copy(frame[HeaderSize+32:], "Xing")
+		b = append(b, frame...)
+	}
+	for range n {
+		b = append(b, testFrame(0x55)...)
+	}
+	return b
+}
+
+func id3v2(size int) []byte {
+	tag := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, byte(size >> 7), byte(size & 0x7f)}
+	return append(tag, make([]byte, size)...)
+}
+
+func id3v1() []byte {
+	return append([]byte("TAG"), make([]byte, 125)...)
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		frames    int
+		dataStart int64
+		dataEnd   int64
+		err       error
+	}{
+		{
+			name:    "frames only",
+			data:    testStream(10, false),
+			frames:  10,
+			dataEnd: 10 * frameSize,
+		},
+		{
+			name:      "id3v2 tag",
+			data:      append(id3v2(200), testStream(10, false)...),
+			frames:    10,
+			dataStart: 210,
+			dataEnd:   210 + 10*frameSize,
+		},
+		{
+			name:    "id3v1 tag",
+			data:    append(testStream(10, false), id3v1()...),
+			frames:  10,
+			dataEnd: 10 * frameSize,
+		},
+		{
+			name:      "xing frame",
+			data:      testStream(10, true),
+			frames:    10,
+			dataStart: frameSize,
+			dataEnd:   11 * frameSize,
+		},
+		{
+			// The frame before the junk is not followed by another frame,
+			// so it is taken for junk too.
+			name:    "junk between frames",
+			data:    append(append(testStream(5, false), 0, 1, 2), testStream(5, false)...),
+			frames:  9,
+			dataEnd: 10*frameSize + 3,
+		},
+		{
+			name: "empty",
+			data: nil,
+			err:  ErrNoFrames,
+		},
+		{
+			name: "not audio",
+			data: bytes.Repeat([]byte("not an mp3 "), 100),
+			err:  ErrNoFrames,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Scan(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Scan() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if info.Frames != tt.frames {
+				t.Errorf("Frames = %d, want %d", info.Frames, tt.frames)
+			}
+			if info.DataStart != tt.dataStart || info.DataEnd != tt.dataEnd {
+				t.Errorf("data = %d-%d, want %d-%d", info.DataStart, info.DataEnd, tt.dataStart, tt.dataEnd)
+			}
+			if want := frames(tt.frames); info.Duration != want {
+				t.Errorf("Duration = %v, want %v", info.Duration, want)
+			}
+			if info.SampleRate != 44100 {
+				t.Errorf("SampleRate = %d, want 44100", info.SampleRate)
+			}
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		at     time.Duration
+		offset int64
+		start  time.Duration
+	}{
+		{name: "start", data: testStream(10, false), at: 0, offset: 0, start: 0},
+		{name: "before start", data: testStream(10, false), at: -time.Second, offset: 0, start: 0},
+		{name: "frame boundary", data: testStream(10, false), at: frames(1), offset: frameSize, start: frames(1)},
+		{name: "inside frame", data: testStream(10, false), at: frames(3) + time.Millisecond, offset: 3 * frameSize, start: frames(3)},
+		{name: "past end", data: testStream(10, false), at: time.Hour, offset: 10 * frameSize, start: frames(10)},
+		{name: "xing frame", data: testStream(10, true), at: 0, offset: frameSize, start: 0},
+		{name: "id3v2 tag", data: append(id3v2(20), testStream(10, false)...), at: frames(1), offset: 30 + frameSize, start: frames(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, start, err := Seek(bytes.NewReader(tt.data), tt.at)
+			if err != nil {
+				t.Fatalf("Seek() error = %v", err)
+			}
+			if offset != tt.offset || start != tt.start {
+				t.Errorf("Seek(%v) = %d, %v, want %d, %v", tt.at, offset, start, tt.offset, tt.start)
+			}
+		})
+	}
+
+	if _, _, err := Seek(bytes.NewReader(nil), 0); !errors.Is(err, ErrNoFrames) {
+		t.Errorf("Seek() of empty stream error = %v, want %v", err, ErrNoFrames)
+	}
+}
+
+func TestInfoSeek(t *testing.T) {
+	// Enough frames for several seek points.
+	data := append(id3v2(100), testStream(3*seekInterval+10, true)...)
+	info, err := Scan(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(info.index) != 4 {
+		t.Fatalf("index has %d points, want 4", len(info.index))
+	}
+
+	r := bytes.NewReader(data)
+	for _, at := range []time.Duration{
+		-time.Second,
+		0,
+		frames(1) / 2,
+		frames(seekInterval),
+		frames(seekInterval) - time.Millisecond,
+		frames(2*seekInterval) + 5*time.Millisecond,
+		info.Duration - time.Millisecond,
+		info.Duration + time.Minute,
+	} {
+		wantOffset, wantStart, err := Seek(bytes.NewReader(data), at)
+		if err != nil {
+			t.Fatalf("Seek(%v) error = %v", at, err)
+		}
+		// Leave r somewhere else to check that Info.Seek positions it.
+		r.Seek(int64(len(data)/2), 0)
+		offset, start, err := info.Seek(r, at)
+		if err != nil {
+			t.Fatalf("Info.Seek(%v) error = %v", at, err)
+		}
+		if offset != wantOffset || start != wantStart {
+			t.Errorf("Info.Seek(%v) = %d, %v, want %d, %v", at, offset, start, wantOffset, wantStart)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package radio
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Pertsaa/go-radio/internal/artwork"
+)
+
+// artworkNames are the files looked up in a channel directory for its cover.
+var artworkNames = []string{"cover.jpg", "cover.jpeg", "cover.png"}
+
+// findArtwork returns the path and contents of the cover image in dir.
+func findArtwork(dir string) (path string, data []byte, err error) {
+	for _, name := range artworkNames {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := artwork.ContentType(data); err != nil {
+			return "", nil, err
+		}
+		return path, data, nil
+	}
+	return "", nil, nil
+}
+
+// ChannelArtwork returns a channel's cover image scaled to fit size pixels,
+// which is rounded up to one of artwork.Sizes. Zero selects the original.
+func (r *Radio) ChannelArtwork(channelID string, size int) (artwork.Image, error) {
+	channel, ok := r.GetChannel(channelID)
+	if !ok {
+		return artwork.Image{}, ErrChannelNotFound
+	}
+	if channel.artworkPath == "" {
+		return artwork.Image{}, ErrArtworkNotFound
+	}
+
+	data, err := os.ReadFile(channel.artworkPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return artwork.Image{}, ErrArtworkNotFound
+	}
+	if err != nil {
+		return artwork.Image{}, err
+	}
+	return r.artwork.Thumbnail(data, artwork.FitSize(size))
+}
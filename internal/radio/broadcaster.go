@@ -0,0 +1,690 @@
+package radio
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/mp3"
+	"github.com/Pertsaa/go-radio/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	maxIOAttempts = 3
+	ioRetryDelay  = 200 * time.Millisecond
+)
+
+var (
+	errNoTracks       = errors.New("no .mp3 audio files found")
+	errAllQuarantined = errors.New("all audio files are quarantined")
+)
+
+type ChannelStatus string
+
+const (
+	ChannelStatusStarting ChannelStatus = "starting"
+	ChannelStatusOnAir    ChannelStatus = "on_air"
+	ChannelStatusDegraded ChannelStatus = "degraded"
+	ChannelStatusOffline  ChannelStatus = "offline"
+	ChannelStatusStopped  ChannelStatus = "stopped"
+)
+
+type QuarantinedTrack struct {
+	Name    string    `json:"name"`
+	Reason  string    `json:"reason"`
+	Since   time.Time `json:"since"`
+	modTime time.Time
+}
+
+// broadcaster holds the state of a channel's broadcast loop. It outlives
+// individual runs of the loop so counters and quarantined tracks survive
+// restarts by the supervisor.
+type broadcaster struct {
+	channel      Channel
+	settings     Settings
+	buffer       *RingBuffer
+	chunks       atomic.Int64
+	trackChanges atomic.Int64
+	openErrors   atomic.Int64
+	readErrors   atomic.Int64
+	restarts     atomic.Int64
+	lastChunkAt  atomic.Int64
+	// timelineLag is how far a timeline channel is behind its schedule, in
+	// nanoseconds.
+	timelineLag atomic.Int64
+	// emptySince is when the last listener left, in Unix nanoseconds, or
+	// zero while the channel has listeners.
+	emptySince atomic.Int64
+	wake       chan struct{}
+
+	mu        sync.Mutex
+	running   bool
+	idle      bool
+	track     string
+	trackPath string
+	trackAt   time.Time
+	// part is the part of the track's cue sheet on air since partAt.
+	part       *cuePart
+	partAt     time.Time
+	taps       map[*recorder]struct{}
+	shift      *timeShift
+	status     ChannelStatus
+	lastError  string
+	startedAt  time.Time
+	runChunks  int64
+	quarantine map[string]QuarantinedTrack
+}
+
+func newBroadcaster(channel Channel, settings Settings) *broadcaster {
+	b := &broadcaster{
+		channel:    channel,
+		settings:   settings,
+		buffer:     NewRingBuffer(settings.BufferLength),
+		wake:       make(chan struct{}, 1),
+		status:     ChannelStatusStarting,
+		quarantine: make(map[string]QuarantinedTrack),
+	}
+	b.emptySince.Store(time.Now().UnixNano())
+	return b
+}
+
+// lag returns how far the broadcaster is behind the wall clock, based on the
+// number of chunks sent since the current run started.
+func (b *broadcaster) lag() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.running || b.idle {
+		return 0
+	}
+	if b.settings.Mode == ModeTimeline {
+		return time.Duration(b.timelineLag.Load())
+	}
+	return time.Since(b.startedAt) - time.Duration(b.chunks.Load()-b.runChunks)*b.settings.TickInterval
+}
+
+func (b *broadcaster) isRunning() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.running
+}
+
+func (b *broadcaster) setRunning(running bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.running = running
+	if running {
+		b.startedAt = time.Now()
+		b.runChunks = b.chunks.Load()
+		b.updateStatus()
+	}
+}
+
+func (b *broadcaster) setStatus(status ChannelStatus, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status = status
+	b.lastError = ""
+	if err != nil {
+		b.lastError = err.Error()
+	}
+}
+
+// updateStatus must be called with mu held.
+func (b *broadcaster) updateStatus() {
+	if len(b.quarantine) > 0 {
+		b.status = ChannelStatusDegraded
+		return
+	}
+	b.status = ChannelStatusOnAir
+	b.lastError = ""
+}
+
+func (b *broadcaster) quarantineTrack(name string, modTime time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.quarantine[name] = QuarantinedTrack{
+		Name:    name,
+		Reason:  err.Error(),
+		Since:   time.Now(),
+		modTime: modTime,
+	}
+	b.lastError = err.Error()
+	b.updateStatus()
+}
+
+// isQuarantined reports whether a track is quarantined. A quarantined track
+// is released once its file has been modified.
+func (b *broadcaster) isQuarantined(name string, modTime time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.quarantine[name]
+	if !ok {
+		return false
+	}
+	if !modTime.IsZero() && !modTime.Equal(q.modTime) {
+		delete(b.quarantine, name)
+		return false
+	}
+	return true
+}
+
+func (b *broadcaster) quarantined() []QuarantinedTrack {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make([]QuarantinedTrack, 0, len(b.quarantine))
+	for _, q := range b.quarantine {
+		result = append(result, q)
+	}
+	slices.SortFunc(result, func(a, b QuarantinedTrack) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result
+}
+
+func (r *Radio) getBroadcaster(channelID string) (*broadcaster, bool) {
+	r.broadcasterMux.Lock()
+	defer r.broadcasterMux.Unlock()
+	b, ok := r.broadcasterMap[channelID]
+	return b, ok
+}
+
+func (r *Radio) channelDir(channel Channel) string {
+	return filepath.Join(r.dir, channel.Name)
+}
+
+// scanTracks lists the playable audio files of a channel in rotation order.
+// A track with a weight from its overrides appears that many times, spread
+// evenly through the rotation.
+func (r *Radio) scanTracks(b *broadcaster) ([]AudioSource, error) {
+	entries, err := os.ReadDir(r.channelDir(b.channel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audio files: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".mp3") {
+			names = append(names, entry.Name())
+		}
+	}
+
+	type slot struct {
+		name string
+		at   float64
+	}
+	var slots []slot
+	for i, name := range names {
+		o, err := r.trackOverride(filepath.Join(r.channelDir(b.channel), name))
+		if err != nil {
+			r.logger(b.channel).Warn("ignoring track overrides", "track", name, "error", err)
+		}
+		weight := o.weight()
+		for k := range weight {
+			at := (float64(i)+0.5)/float64(len(names)) + float64(k)/float64(weight)
+			slots = append(slots, slot{name: name, at: at - math.Floor(at)})
+		}
+	}
+	slices.SortStableFunc(slots, func(a, b slot) int {
+		return cmp.Compare(a.at, b.at)
+	})
+
+	audioSources := []AudioSource{}
+	for _, s := range slots {
+		audioSources = append(audioSources, AudioSource{ID: uuid.NewString(), Name: s.name})
+	}
+
+	if len(audioSources) == 0 {
+		return nil, errNoTracks
+	}
+
+	return audioSources, nil
+}
+
+// trackPlan describes which part of a track to play and how fast.
+type trackPlan struct {
+	path  string
+	index int
+	// offset is the byte offset to start at in sequential mode.
+	offset int64
+	// In timeline mode info is set and reads follow the wall clock instead
+	// of a fixed chunk size. The track started, or will start, at startedAt
+	// and playback begins at time at into it.
+	info      *mp3.Info
+	startedAt time.Time
+	at        time.Duration
+	// cueIn and cueOut trim the track; a zero cueOut plays to the end. In
+	// timeline mode at counts from cueIn.
+	cueIn  time.Duration
+	cueOut time.Duration
+	// gain is the volume change in global gain steps.
+	gain int
+	// cues follows the parts of a file with a cue sheet.
+	cues *cueTracker
+}
+
+// runChannel streams the channel's tracks until the context is cancelled or
+// the channel can no longer play anything.
+func (r *Radio) runChannel(ctx context.Context, b *broadcaster) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("broadcast loop panicked: %v", p)
+		}
+	}()
+
+	audioSources, err := r.scanTracks(b)
+	if err != nil {
+		return err
+	}
+
+	if b.settings.Mode == ModeTimeline {
+		tl, err := r.loadTimeline(b, audioSources)
+		if err != nil {
+			return err
+		}
+		b.setRunning(true)
+		defer b.setRunning(false)
+		return r.runTimeline(ctx, b, tl)
+	}
+
+	b.setRunning(true)
+	defer b.setRunning(false)
+	return r.runSequential(ctx, b, audioSources)
+}
+
+// runSequential plays the tracks in order, starting from the saved position.
+func (r *Radio) runSequential(ctx context.Context, b *broadcaster, audioSources []AudioSource) error {
+	logger := r.logger(b.channel)
+
+	readBuffer := make([]byte, b.settings.ChunkSize)
+	ticker := time.NewTicker(b.settings.TickInterval)
+	defer ticker.Stop()
+
+	currentFileIndex, offset := r.resumePosition(b, audioSources)
+	skipped := 0
+
+	for {
+		if skipped >= len(audioSources) {
+			return errAllQuarantined
+		}
+
+		index := currentFileIndex
+		source := audioSources[index]
+		currentFileIndex = (currentFileIndex + 1) % len(audioSources)
+
+		filePath := filepath.Join(r.channelDir(b.channel), source.Name)
+
+		var modTime time.Time
+		if info, err := os.Stat(filePath); err == nil {
+			modTime = info.ModTime()
+		}
+
+		if b.isQuarantined(source.Name, modTime) {
+			skipped++
+			offset = 0
+			continue
+		}
+
+		plan := trackPlan{path: filePath, index: index, offset: offset}
+		r.applyTrackOverride(b, &plan)
+		err := r.playAndRecord(ctx, b, plan, ticker, readBuffer)
+		offset = 0
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if idle := (*idleError)(nil); errors.As(err, &idle) {
+			pausedAt := time.Now()
+			if !r.sleep(ctx, b) {
+				return nil
+			}
+			currentFileIndex, offset = r.advance(b, audioSources, index, idle.offset, time.Since(pausedAt))
+			continue
+		}
+		if err != nil {
+			logger.Error("quarantining track", "track", source.Name, "error", err)
+			b.quarantineTrack(source.Name, modTime, err)
+			skipped++
+			continue
+		}
+
+		skipped = 0
+	}
+}
+
+// runTimeline plays whatever the timeline says is on air. A track that cannot
+// be played leaves its slot silent so the channel stays in step with other
+// instances.
+func (r *Radio) runTimeline(ctx context.Context, b *broadcaster, tl *timeline) error {
+	logger := r.logger(b.channel)
+
+	readBuffer := make([]byte, b.settings.ChunkSize)
+	ticker := time.NewTicker(b.settings.TickInterval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	index, at := tl.at(now)
+	startedAt := now.Add(-at)
+
+	for {
+		track := tl.tracks[index]
+		filePath := filepath.Join(r.channelDir(b.channel), track.name)
+		endsAt := startedAt.Add(track.length)
+
+		var modTime time.Time
+		if info, err := os.Stat(filePath); err == nil {
+			modTime = info.ModTime()
+		}
+
+		if !b.isQuarantined(track.name, modTime) {
+			plan := trackPlan{path: filePath, index: index, info: &track.info, startedAt: startedAt, at: at}
+			plan.cueIn, plan.gain = track.cueIn, track.gain
+			if out := track.cueIn + track.length; out < track.info.Duration {
+				plan.cueOut = out
+			}
+			err := r.playAndRecord(ctx, b, plan, ticker, readBuffer)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if idle := (*idleError)(nil); errors.As(err, &idle) {
+				if !r.sleep(ctx, b) {
+					return nil
+				}
+				now := time.Now()
+				index, at = tl.at(now)
+				startedAt = now.Add(-at)
+				continue
+			}
+			if err != nil {
+				logger.Error("quarantining track", "track", track.name, "error", err)
+				b.quarantineTrack(track.name, modTime, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(endsAt)):
+		}
+
+		// The next track is scheduled right after this one, unless playback
+		// fell so far behind that the timeline has moved on.
+		index, at, startedAt = (index+1)%len(tl.tracks), 0, endsAt
+		if now := time.Now(); now.Sub(startedAt) > tl.tracks[index].length {
+			index, at = tl.at(now)
+			startedAt = now.Add(-at)
+		} else if now.After(startedAt) {
+			at = now.Sub(startedAt)
+		}
+	}
+}
+
+// playAndRecord plays a track and adds it to the play history.
+func (r *Radio) playAndRecord(ctx context.Context, b *broadcaster, plan trackPlan, ticker *time.Ticker, readBuffer []byte) error {
+	play := store.Play{
+		Track:     filepath.Base(plan.path),
+		Kind:      store.PlayKindTrack,
+		StartedAt: time.Now(),
+		Listeners: r.ListenerCount(b.channel.ID),
+	}
+	plan.cues = r.newCueTracker(b, plan.path, &play)
+
+	err := r.playTrack(ctx, b, plan, ticker, readBuffer)
+
+	play.EndedAt = time.Now()
+	var idle *idleError
+	play.Skipped = (err != nil && !errors.As(err, &idle)) || ctx.Err() != nil
+	r.recordPlay(b, play)
+
+	return err
+}
+
+// playTrack streams a single file as described by plan, retrying transient
+// I/O errors. In sequential mode the position is saved periodically and when
+// the track stops so playback can resume after a restart.
+func (r *Radio) playTrack(ctx context.Context, b *broadcaster, plan trackPlan, ticker *time.Ticker, readBuffer []byte) error {
+	logger := r.logger(b.channel).With("track", filepath.Base(plan.path))
+
+	var file *os.File
+	err := retryIO(ctx, func() error {
+		var err error
+		file, err = os.Open(plan.path)
+		if err != nil {
+			b.openErrors.Add(1)
+			logger.Warn("failed to open audio file", "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Cue points are found through the scan cached for the track, so only
+	// the frames around them are read.
+	seekInfo := plan.info
+	if seekInfo == nil && (plan.cueIn > 0 || plan.cueOut > 0) {
+		scanned, err := r.trackInfo(plan.path)
+		if err != nil {
+			return err
+		}
+		seekInfo = &scanned
+	}
+
+	// end is where playback stops, or 0 at the end of the file.
+	var end int64
+	if plan.info != nil {
+		end = plan.info.DataEnd
+	}
+	if plan.cueOut > 0 {
+		cueEnd, _, err := seekInfo.Seek(file, plan.cueOut)
+		if err != nil {
+			return err
+		}
+		if end == 0 || cueEnd < end {
+			end = cueEnd
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	offset := plan.offset
+	var due func(now time.Time) int64
+	switch {
+	case plan.info != nil:
+		var start time.Duration
+		offset, start, err = plan.info.Seek(file, plan.cueIn+plan.at)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		// Bytes are due at the track's average rate from the moment the
+		// first frame is scheduled.
+		base, frameAt, rate := offset, plan.startedAt.Add(start-plan.cueIn), plan.info.ByteRate()
+		due = func(now time.Time) int64 {
+			return base + int64(rate*now.Sub(frameAt).Seconds())
+		}
+	case plan.cueIn > 0:
+		cueStart, _, err := seekInfo.Seek(file, plan.cueIn)
+		if err != nil {
+			return err
+		}
+		if offset > cueStart {
+			offset, err = seekFrame(file, offset)
+		} else {
+			offset = cueStart
+			_, err = file.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			return err
+		}
+	case offset > 0:
+		offset, err = seekFrame(file, offset)
+		if err != nil {
+			return err
+		}
+	}
+	if end > 0 && offset >= end {
+		// Resumed past the cue-out point.
+		return nil
+	}
+
+	playback := store.Playback{
+		Track:   filepath.Base(plan.path),
+		Index:   plan.index,
+		Offset:  offset,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	persist := plan.info == nil
+	lastSave := time.Now()
+	if persist {
+		r.savePlayback(b, playback)
+		defer func() {
+			r.savePlayback(b, playback)
+		}()
+	}
+
+	var gain *mp3.GainFilter
+	if plan.gain != 0 {
+		gain = mp3.NewGainFilter(plan.gain)
+	}
+	send := func(data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		now := time.Now()
+		b.buffer.Write(data)
+		b.chunks.Add(1)
+		b.lastChunkAt.Store(now.UnixNano())
+
+		chunk := AudioChunk{Data: data, Time: now}
+		r.publish(b, chunk)
+		r.shiftWrite(b, chunk)
+	}
+	// finish sends what the gain filter still holds once the track is done.
+	finish := func() error {
+		if gain != nil {
+			send(gain.Flush())
+		}
+		return nil
+	}
+
+	b.trackChanges.Add(1)
+
+	logger.Info("streaming", "offset", offset)
+	b.trackStarted(plan.path, time.Now())
+	if plan.cues != nil {
+		plan.cues.seek(b, offset, time.Now())
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if b.shouldIdle(time.Now()) {
+			return &idleError{offset: playback.Offset}
+		}
+
+		size := len(readBuffer)
+		if due != nil {
+			size = int(min(due(time.Now()), end) - playback.Offset)
+			if size <= 0 {
+				continue
+			}
+			if size > len(readBuffer) {
+				readBuffer = make([]byte, size)
+			}
+		} else if end > 0 {
+			size = int(min(int64(size), end-playback.Offset))
+		}
+
+		var n int
+		err := retryIO(ctx, func() error {
+			var err error
+			n, err = file.Read(readBuffer[:size])
+			if err != nil && err != io.EOF {
+				b.readErrors.Add(1)
+				logger.Warn("error reading audio file", "error", err)
+			}
+			return err
+		})
+		if err == io.EOF {
+			return finish()
+		}
+		if err != nil {
+			return err
+		}
+
+		chunkData := make([]byte, n)
+		copy(chunkData, readBuffer[:n])
+		if gain != nil {
+			chunkData = gain.Apply(chunkData)
+		}
+		send(chunkData)
+
+		now := time.Now()
+		playback.Offset += int64(n)
+		if due != nil {
+			behind := float64(due(now)-playback.Offset) / plan.info.ByteRate()
+			b.timelineLag.Store(int64(max(behind, 0) * float64(time.Second)))
+		}
+		if plan.cues != nil {
+			r.advanceCue(b, plan.cues, playback.Offset, now)
+		}
+		if end > 0 && playback.Offset >= end {
+			return finish()
+		}
+		if persist && now.Sub(lastSave) >= playbackSaveInterval {
+			r.savePlayback(b, playback)
+			lastSave = now
+		}
+	}
+}
+
+// retryIO runs fn until it succeeds, returns io.EOF or a permanent error, or
+// the attempts run out.
+func retryIO(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxIOAttempts; attempt++ {
+		err = fn()
+		if err == nil || err == io.EOF || !isTransient(err) {
+			return err
+		}
+		if attempt < maxIOAttempts {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(ioRetryDelay * time.Duration(attempt)):
+			}
+		}
+	}
+	return err
+}
+
+func isTransient(err error) bool {
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) && !errors.Is(err, fs.ErrInvalid)
+}
@@ -40,3 +40,10 @@ func (b *RingBuffer) ReadAll() [][]byte {
 	}
 	return result
 }
+
+func (b *RingBuffer) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	clear(b.data)
+	b.head = 0
+}
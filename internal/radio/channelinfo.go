@@ -0,0 +1,123 @@
+package radio
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Pertsaa/go-radio/internal/artwork"
+	"gopkg.in/yaml.v3"
+)
+
+// channelInfoNames are the metadata files looked up in a channel directory,
+// in order of preference.
+var channelInfoNames = []string{"channel.json", "channel.yaml", "channel.yml"}
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// channelInfo is the optional metadata file of a channel.
+type channelInfo struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Genre       string   `json:"genre" yaml:"genre"`
+	Tags        []string `json:"tags" yaml:"tags"`
+	Order       int      `json:"order" yaml:"order"`
+	Color       string   `json:"color" yaml:"color"`
+	Default     bool     `json:"default" yaml:"default"`
+}
+
+// readChannelInfo reads the metadata file in dir, if there is one.
+func readChannelInfo(dir string) (channelInfo, error) {
+	var info channelInfo
+	for _, name := range channelInfoNames {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return info, err
+		}
+
+		if filepath.Ext(name) == ".json" {
+			err = json.Unmarshal(data, &info)
+		} else {
+			err = yaml.Unmarshal(data, &info)
+		}
+		if err != nil {
+			return info, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if info.Color != "" && !colorPattern.MatchString(info.Color) {
+			return info, fmt.Errorf("invalid %s: color must look like #1e90ff", name)
+		}
+		return info, nil
+	}
+	return info, nil
+}
+
+// describeChannel fills in the channel's metadata and artwork from its
+// directory. Problems are logged and leave the defaults in place.
+func (r *Radio) describeChannel(channel *Channel) {
+	dir := r.channelDir(*channel)
+	logger := r.logger(*channel)
+
+	channel.DisplayName = channel.Name
+
+	info, err := readChannelInfo(dir)
+	if err != nil {
+		logger.Warn("ignoring channel metadata", "error", err)
+	} else {
+		if info.Name != "" {
+			channel.DisplayName = info.Name
+		}
+		channel.Description = info.Description
+		channel.Genre = info.Genre
+		channel.Tags = info.Tags
+		channel.Order = info.Order
+		channel.Color = info.Color
+		channel.Default = info.Default
+	}
+
+	path, data, err := findArtwork(dir)
+	if err == nil && path != "" {
+		_, err = r.artwork.Prepare(data)
+	}
+	if err != nil {
+		logger.Warn("ignoring channel artwork", "error", err)
+		return
+	}
+	if path != "" {
+		channel.artworkPath = path
+		channel.Artwork = "/radio/channels/" + channel.ID + "/artwork?v=" + artwork.Hash(data)
+	}
+}
+
+// sortChannels orders channels by their configured order, then display name,
+// and keeps only the first default channel.
+func (r *Radio) sortChannels(channels []Channel) {
+	slices.SortStableFunc(channels, func(a, b Channel) int {
+		return cmp.Or(
+			cmp.Compare(a.Order, b.Order),
+			cmp.Compare(strings.ToLower(a.DisplayName), strings.ToLower(b.DisplayName)),
+		)
+	})
+
+	hasDefault := false
+	for i := range channels {
+		if !channels[i].Default {
+			continue
+		}
+		if hasDefault {
+			r.logger(channels[i]).Warn("another channel is already the default")
+			channels[i].Default = false
+		}
+		hasDefault = true
+	}
+}
@@ -0,0 +1,191 @@
+package radio
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/cue"
+	"github.com/Pertsaa/go-radio/internal/store"
+)
+
+// cuePart is a track inside a long file, such as a DJ mix, as listed by the
+// file's cue sheet.
+type cuePart struct {
+	Number int
+	Title  string
+	Artist string
+	Album  string
+	Start  time.Duration
+	// End is where the next part starts, or the end of the file.
+	End time.Duration
+}
+
+// label names the part in recording cues and the time-shift buffer.
+func (p cuePart) label() string {
+	if p.Artist == "" {
+		return p.Title
+	}
+	return p.Artist + " - " + p.Title
+}
+
+// findCueSheet returns the path and modification time of the cue sheet of
+// the track at path, named song.cue or song.mp3.cue, or an empty path if it
+// has none.
+func findCueSheet(path string) (string, time.Time) {
+	for _, name := range []string{strings.TrimSuffix(path, filepath.Ext(path)) + ".cue", path + ".cue"} {
+		if stat, err := os.Stat(name); err == nil && !stat.IsDir() {
+			return name, stat.ModTime()
+		}
+	}
+	return "", time.Time{}
+}
+
+// cueParts returns the parts of the track at path, which lasts duration,
+// from its cue sheet. Tracks without a cue sheet have no parts.
+func (r *Radio) cueParts(path string, duration time.Duration) ([]cuePart, error) {
+	cuePath, _ := findCueSheet(path)
+	if cuePath == "" {
+		return nil, nil
+	}
+	sheet, err := r.cueSheets.get(cuePath, func(file *os.File) (*cue.Sheet, error) {
+		return cue.Parse(file)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Base(cuePath), err)
+	}
+
+	tracks := sheet.TracksOf(filepath.Base(path))
+	var parts []cuePart
+	for i, t := range tracks {
+		if t.Start >= duration {
+			break
+		}
+		part := cuePart{
+			Number: t.Number,
+			Title:  t.Title,
+			Artist: cmp.Or(t.Performer, sheet.Performer),
+			Album:  sheet.Title,
+			Start:  t.Start,
+			End:    duration,
+		}
+		if i+1 < len(tracks) {
+			part.End = min(tracks[i+1].Start, duration)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// cueTracker follows playback of a file through the parts of its cue sheet.
+// The play it is given always describes the part on air, and each part that
+// ends is added to the history.
+type cueTracker struct {
+	parts     []cuePart
+	dataStart int64
+	byteRate  float64
+	// current is the index of the part on air, or -1 before the first.
+	current int
+	play    *store.Play
+}
+
+// newCueTracker returns a tracker for the file at path, or nil if it has no
+// usable cue sheet.
+func (r *Radio) newCueTracker(b *broadcaster, path string, play *store.Play) *cueTracker {
+	info, err := r.trackInfo(path)
+	if err != nil {
+		return nil
+	}
+	parts, err := r.cueParts(path, info.Duration)
+	if err != nil {
+		r.logger(b.channel).Warn("ignoring cue sheet", "track", filepath.Base(path), "error", err)
+		return nil
+	}
+	if len(parts) == 0 || info.ByteRate() <= 0 {
+		return nil
+	}
+	return &cueTracker{parts: parts, dataStart: info.DataStart, byteRate: info.ByteRate(), current: -1, play: play}
+}
+
+// partAt returns the index of the part playing at a byte offset into the
+// file, or -1 before the first.
+func (c *cueTracker) partAt(offset int64) int {
+	pos := time.Duration(float64(offset-c.dataStart) / c.byteRate * float64(time.Second))
+	i := -1
+	for i+1 < len(c.parts) && c.parts[i+1].Start <= pos {
+		i++
+	}
+	return i
+}
+
+// seek starts following playback from offset without adding to the history.
+func (c *cueTracker) seek(b *broadcaster, offset int64, now time.Time) {
+	c.current = c.partAt(offset)
+	if c.current < 0 {
+		return
+	}
+	c.startPart(now)
+	b.partStarted(c.parts[c.current], now)
+}
+
+// advanceCue moves on to the part playing at offset, recording the one that
+// ended.
+func (r *Radio) advanceCue(b *broadcaster, c *cueTracker, offset int64, now time.Time) {
+	i := c.partAt(offset)
+	if i <= c.current {
+		return
+	}
+
+	ended := *c.play
+	ended.EndedAt = now
+	r.recordPlay(b, ended)
+
+	c.current = i
+	c.play.Listeners = r.ListenerCount(b.channel.ID)
+	c.startPart(now)
+	b.partStarted(c.parts[i], now)
+}
+
+// startPart points the play at the current part.
+func (c *cueTracker) startPart(now time.Time) {
+	part := c.parts[c.current]
+	c.play.StartedAt = now
+	c.play.Part, c.play.Title, c.play.Artist = part.Number, part.Title, part.Artist
+}
+
+// partStarted records the part of a cue sheet now on air and passes the
+// change on to recordings and the time-shift buffer.
+func (b *broadcaster) partStarted(part cuePart, at time.Time) {
+	b.mu.Lock()
+	b.part, b.partAt = &part, at
+	b.mu.Unlock()
+
+	if b.shift != nil {
+		b.shift.trackStarted(part.label(), at)
+	}
+
+	b.tap(recorderEvent{track: part.label(), at: at})
+}
+
+// libraryParts lists the parts of the track at path for the library index.
+func (r *Radio) libraryParts(path string, duration time.Duration) ([]store.Track, error) {
+	parts, err := r.cueParts(path, duration)
+	if err != nil {
+		return nil, err
+	}
+	var tracks []store.Track
+	for _, p := range parts {
+		tracks = append(tracks, store.Track{
+			Part:     p.Number,
+			Start:    p.Start.Seconds(),
+			Duration: (p.End - p.Start).Seconds(),
+			Title:    p.Title,
+			Artist:   p.Artist,
+			Album:    p.Album,
+		})
+	}
+	return tracks, nil
+}
@@ -0,0 +1,42 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrChannelNotFound   = errors.New("channel not found")
+	ErrChannelOffline    = errors.New("channel offline")
+	ErrCapacityReached   = errors.New("listener capacity reached")
+	ErrListenerNotFound  = errors.New("listener not found")
+	ErrArtworkNotFound   = errors.New("artwork not found")
+	ErrTimeShiftDisabled = errors.New("time shift is not enabled for this channel")
+
+	ErrRecordingNotFound = errors.New("recording not found")
+	ErrAlreadyRecording  = errors.New("channel is already being recorded")
+	ErrNotRecording      = errors.New("channel is not being recorded")
+)
+
+type LimitScope string
+
+const (
+	LimitScopeServer  LimitScope = "server"
+	LimitScopeChannel LimitScope = "channel"
+	LimitScopeAddr    LimitScope = "address"
+)
+
+// LimitError is returned when a listener is rejected because one of the
+// configured listener limits has been reached.
+type LimitError struct {
+	Scope LimitScope
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s listener limit of %d reached", e.Scope, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrCapacityReached
+}
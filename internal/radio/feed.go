@@ -0,0 +1,38 @@
+package radio
+
+import (
+	"time"
+)
+
+// Episode is a finished recording that can be published in a podcast feed.
+type Episode struct {
+	Recording
+	Duration time.Duration
+}
+
+// Episodes returns a channel's finished recordings with their cue lists,
+// newest first. Recordings that failed, were cut short by a crash, contain no
+// audio or are shorter than the configured minimum are left out.
+func (r *Radio) Episodes(channelID string) ([]Episode, error) {
+	if _, ok := r.GetChannel(channelID); !ok {
+		return nil, ErrChannelNotFound
+	}
+
+	recordings, err := r.scanRecordings()
+	if err != nil {
+		return nil, err
+	}
+
+	episodes := []Episode{}
+	for _, rec := range recordings {
+		if rec.ChannelID != channelID || rec.Active || rec.EndedAt == nil || rec.Error != "" {
+			continue
+		}
+		info, err := r.trackInfo(rec.path)
+		if err != nil || info.Duration < r.recordingOpts.FeedMinDuration {
+			continue
+		}
+		episodes = append(episodes, Episode{Recording: rec, Duration: info.Duration})
+	}
+	return episodes, nil
+}
@@ -0,0 +1,57 @@
+package radio
+
+import "time"
+
+type Readiness struct {
+	Ready    bool               `json:"ready"`
+	Channels []ChannelReadiness `json:"channels"`
+}
+
+type ChannelReadiness struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Status    ChannelStatus `json:"status"`
+	Ready     bool          `json:"ready"`
+	LastAudio *time.Time    `json:"lastAudio,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Readiness reports whether channels are loaded and every channel with audio
+// files has broadcast a chunk within maxSilence. Channels without any audio
+// files and idle channels are reported but do not affect readiness.
+func (r *Radio) Readiness(maxSilence time.Duration) Readiness {
+	readiness := Readiness{
+		Ready:    r.channels != nil,
+		Channels: []ChannelReadiness{},
+	}
+
+	for _, channel := range r.channels {
+		b, ok := r.getBroadcaster(channel.ID)
+		if !ok {
+			continue
+		}
+
+		b.mu.Lock()
+		cr := ChannelReadiness{
+			ID:     channel.ID,
+			Name:   channel.Name,
+			Status: b.status,
+			Error:  b.lastError,
+		}
+		b.mu.Unlock()
+
+		if last := b.lastChunkAt.Load(); last != 0 {
+			t := time.Unix(0, last)
+			cr.LastAudio = &t
+			cr.Ready = time.Since(t) <= maxSilence
+		}
+		if cr.Status == ChannelStatusOffline || b.isIdle() {
+			cr.Ready = true
+		}
+
+		readiness.Ready = readiness.Ready && cr.Ready
+		readiness.Channels = append(readiness.Channels, cr)
+	}
+
+	return readiness
+}
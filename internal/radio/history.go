@@ -0,0 +1,28 @@
+package radio
+
+import (
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/store"
+)
+
+// History returns up to limit plays of a channel that started before the
+// given time, newest first.
+func (r *Radio) History(channelID string, limit int, before time.Time) ([]store.Play, error) {
+	if _, ok := r.GetChannel(channelID); !ok {
+		return nil, ErrChannelNotFound
+	}
+	if r.store == nil {
+		return []store.Play{}, nil
+	}
+	return r.store.History(channelID, limit, before)
+}
+
+func (r *Radio) recordPlay(b *broadcaster, play store.Play) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.AddPlay(b.channel.ID, play); err != nil {
+		r.logger(b.channel).Error("failed to record play", "track", play.Track, "error", err)
+	}
+}
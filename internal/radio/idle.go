@@ -0,0 +1,143 @@
+package radio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/mp3"
+)
+
+// idleError stops a track when a lazy channel has had no listeners for
+// Settings.IdleAfter. offset is where the track stopped.
+type idleError struct {
+	offset int64
+}
+
+func (e *idleError) Error() string {
+	return "channel idle"
+}
+
+// shouldIdle reports whether the channel has been without listeners long
+// enough to be suspended.
+func (b *broadcaster) shouldIdle(now time.Time) bool {
+	if b.settings.IdleAfter <= 0 || b.isRecording() {
+		return false
+	}
+	emptySince := b.emptySince.Load()
+	return emptySince != 0 && now.Sub(time.Unix(0, emptySince)) >= b.settings.IdleAfter
+}
+
+func (b *broadcaster) isIdle() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.idle
+}
+
+func (b *broadcaster) setIdle(idle bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.idle = idle
+	if idle {
+		// Nothing is playing; a recording started now waits for the next
+		// track instead of citing the one that was interrupted.
+		b.track, b.trackPath, b.part = "", "", nil
+	} else {
+		// Lag is measured from when the channel woke up.
+		b.startedAt = time.Now()
+		b.runChunks = b.chunks.Load()
+	}
+}
+
+// listenersChanged must be called with listenerMux held whenever a listener
+// joins or leaves the channel.
+func (b *broadcaster) listenersChanged(count int) {
+	if count > 0 {
+		b.emptySince.Store(0)
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+	b.emptySince.Store(time.Now().UnixNano())
+}
+
+// sleep suspends a channel until a listener joins. The channel stays on air,
+// but reads no files until then. It returns false if ctx ended first.
+func (r *Radio) sleep(ctx context.Context, b *broadcaster) bool {
+	r.logger(b.channel).Info("channel idle")
+
+	b.setIdle(true)
+	b.buffer.Reset()
+	defer b.setIdle(false)
+
+	// Drain a wake-up left over from a listener that has gone again.
+	select {
+	case <-b.wake:
+	default:
+	}
+
+	for b.shouldIdle(time.Now()) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-b.wake:
+		}
+	}
+
+	r.logger(b.channel).Info("channel woke up")
+	return true
+}
+
+// advance returns where a sequential channel would be had it kept playing for
+// elapsed from the given track and byte offset. Tracks play between their cue
+// points, and tracks that are quarantined or fail to load are skipped, as
+// they would have been. If nothing has any length, the position is returned
+// unchanged.
+func (r *Radio) advance(b *broadcaster, sources []AudioSource, index int, offset int64, elapsed time.Duration) (int, int64) {
+	type span struct {
+		info          mp3.Info
+		cueIn, length time.Duration
+	}
+	spans := make([]span, len(sources))
+	var total time.Duration
+	for i, source := range sources {
+		path := filepath.Join(r.channelDir(b.channel), source.Name)
+		var modTime time.Time
+		if fi, err := os.Stat(path); err == nil {
+			modTime = fi.ModTime()
+		}
+		if b.isQuarantined(source.Name, modTime) {
+			continue
+		}
+		info, err := r.trackInfo(path)
+		if err != nil {
+			continue
+		}
+		// Invalid overrides are ignored when the track plays, too.
+		o, _ := r.trackOverride(path)
+		in, out := o.cues(info.Duration)
+		spans[i] = span{info: info, cueIn: in, length: out - in}
+		total += out - in
+	}
+	if total <= 0 {
+		return index, offset
+	}
+
+	var into time.Duration
+	if current := spans[index]; current.length > 0 && current.info.ByteRate() > 0 {
+		into = time.Duration(float64(max(offset-current.info.DataStart, 0)) / current.info.ByteRate() * float64(time.Second))
+		into = min(max(into-current.cueIn, 0), current.length)
+	}
+	into = (into + elapsed) % total
+
+	for into >= spans[index].length {
+		into -= spans[index].length
+		index = (index + 1) % len(sources)
+	}
+
+	next := spans[index]
+	return index, next.info.DataStart + int64((next.cueIn+into).Seconds()*next.info.ByteRate())
+}
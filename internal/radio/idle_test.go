@@ -0,0 +1,94 @@
+package radio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testFrameSize is the length of an MPEG-1 Layer III frame at 128 kbit/s
+// and 44.1 kHz, which plays for 1152 samples.
+const testFrameSize = 417
+
+// writeTrack writes an mp3 file of n silent frames to the channel directory.
+func writeTrack(t *testing.T, dir, name string, n int) {
+	t.Helper()
+	frame := make([]byte, testFrameSize)
+	copy(frame, frameHeader)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), bytes.Repeat(frame, n), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// framesDuration returns how long n test frames play.
+func framesDuration(n int) time.Duration {
+	return time.Duration(int64(n) * 1152 * int64(time.Second) / 44100)
+}
+
+func TestAdvance(t *testing.T) {
+	r := newTestRadio(t, "a", "b")
+
+	a := r.broadcasterMap["a"]
+	dir := r.channelDir(a.channel)
+	writeTrack(t, dir, "1.mp3", 100)
+	writeTrack(t, dir, "2.mp3", 50)
+	sources := []AudioSource{{Name: "1.mp3"}, {Name: "2.mp3"}}
+
+	// Channel b plays frames 10 to 60 of its first track. The second is
+	// quarantined and the third has no frames, so both are skipped.
+	b := r.broadcasterMap["b"]
+	dir = r.channelDir(b.channel)
+	writeTrack(t, dir, "1.mp3", 100)
+	writeFile(t, filepath.Join(dir, "1.mp3.json"), fmt.Sprintf(`{"cue_in": %v, "cue_out": %v}`,
+		framesDuration(10).Seconds(), framesDuration(60).Seconds()))
+	writeTrack(t, dir, "2.mp3", 50)
+	fi, err := os.Stat(filepath.Join(dir, "2.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.quarantineTrack("2.mp3", fi.ModTime(), errors.New("broken"))
+	writeTrack(t, dir, "3.mp3", 0)
+	cued := []AudioSource{{Name: "1.mp3"}, {Name: "2.mp3"}, {Name: "3.mp3"}}
+
+	tests := []struct {
+		name    string
+		b       *broadcaster
+		sources []AudioSource
+		index   int
+		frame   int
+		elapsed time.Duration
+		// wantIndex and wantFrame give the expected track and frame.
+		wantIndex int
+		wantFrame int
+	}{
+		{name: "nothing elapsed", b: a, sources: sources, index: 0, frame: 30, wantIndex: 0, wantFrame: 30},
+		{name: "same track", b: a, sources: sources, index: 0, frame: 0, elapsed: framesDuration(10), wantIndex: 0, wantFrame: 10},
+		{name: "next track", b: a, sources: sources, index: 0, frame: 90, elapsed: framesDuration(20), wantIndex: 1, wantFrame: 10},
+		{name: "wraps around", b: a, sources: sources, index: 1, frame: 40, elapsed: framesDuration(20), wantIndex: 0, wantFrame: 10},
+		{name: "several rounds", b: a, sources: sources, index: 0, frame: 0, elapsed: framesDuration(3*150 + 5), wantIndex: 0, wantFrame: 5},
+		{name: "missing track", b: a, sources: []AudioSource{{Name: "1.mp3"}, {Name: "missing.mp3"}}, index: 0, frame: 30, elapsed: framesDuration(105), wantIndex: 0, wantFrame: 35},
+		{name: "cue points", b: b, sources: cued, index: 0, frame: 20, elapsed: framesDuration(20), wantIndex: 0, wantFrame: 40},
+		{name: "before cue in", b: b, sources: cued, index: 0, frame: 0, elapsed: framesDuration(5), wantIndex: 0, wantFrame: 15},
+		{name: "skips quarantined and empty tracks", b: b, sources: cued, index: 0, frame: 50, elapsed: framesDuration(15), wantIndex: 0, wantFrame: 15},
+		{name: "nothing to play", b: b, sources: cued[1:], index: 0, frame: 30, elapsed: time.Hour, wantIndex: 0, wantFrame: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, offset := r.advance(tt.b, tt.sources, tt.index, int64(tt.frame*testFrameSize), tt.elapsed)
+			want := int64(tt.wantFrame * testFrameSize)
+			// The offset is derived from the average byte rate, so allow
+			// for rounding.
+			if index != tt.wantIndex || offset < want-1 || offset > want+1 {
+				t.Errorf("advance() = %d, %d, want %d, %d", index, offset, tt.wantIndex, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package radio
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/id3"
+	"github.com/Pertsaa/go-radio/internal/mp3"
+	"github.com/Pertsaa/go-radio/internal/store"
+)
+
+// fileCache holds values derived from files, valid as long as each file's
+// size and modification time are unchanged.
+type fileCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]cachedFile[T]
+}
+
+type cachedFile[T any] struct {
+	value   T
+	size    int64
+	modTime time.Time
+}
+
+// get returns the value for the file at path, calling load when the file is
+// new or has changed.
+func (c *fileCache[T]) get(path string, load func(*os.File) (T, error)) (T, error) {
+	var zero T
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return zero, err
+	}
+
+	c.mu.Lock()
+	cached, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && cached.size == stat.Size() && cached.modTime.Equal(stat.ModTime()) {
+		return cached.value, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return zero, err
+	}
+	defer file.Close()
+
+	value, err := load(file)
+	if err != nil {
+		return zero, err
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedFile[T])
+	}
+	c.entries[path] = cachedFile[T]{value: value, size: stat.Size(), modTime: stat.ModTime()}
+	c.mu.Unlock()
+
+	return value, nil
+}
+
+// trackInfo scans the file at path for its exact duration. Results are cached
+// until the file changes.
+func (r *Radio) trackInfo(path string) (mp3.Info, error) {
+	return r.trackInfos.get(path, func(file *os.File) (mp3.Info, error) {
+		return mp3.Scan(file)
+	})
+}
+
+// put stores a value for the file at path as loaded at the given size and
+// modification time.
+func (c *fileCache[T]) put(path string, value T, size int64, modTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedFile[T])
+	}
+	c.entries[path] = cachedFile[T]{value: value, size: size, modTime: modTime}
+}
+
+// library is the in-memory copy of the track index.
+type library struct {
+	mu     sync.RWMutex
+	loaded bool
+	tracks map[string]store.Track
+	// sorted holds the tracks in listing order, followed in their place by
+	// the parts of files with a cue sheet.
+	sorted []indexedTrack
+}
+
+type indexedTrack struct {
+	store.Track
+	// text is the lowercased searchable text of the track.
+	text string
+}
+
+func newIndexedTrack(t store.Track) indexedTrack {
+	text := searchText(strings.Join([]string{t.Title, t.Artist, t.Album, filepath.Base(t.Path)}, "\n"))
+	return indexedTrack{Track: t, text: text}
+}
+
+// accents maps accented Latin letters to the letters searches match them by.
+var accents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "æ", "ae",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "œ", "oe",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+)
+
+// searchText normalises s for matching: lowercase, without accents.
+func searchText(s string) string {
+	return accents.Replace(strings.ToLower(s))
+}
+
+// LibraryQuery selects tracks from the library. Text matches tracks whose
+// title, artist, album or file name contain every word. Channel is a channel
+// ID or directory name. The other filters match whole values, ignoring case.
+type LibraryQuery struct {
+	Text    string
+	Channel string
+	Artist  string
+	Album   string
+	Genre   string
+	Offset  int
+	Limit   int
+}
+
+// LibraryPage is one page of a library search.
+type LibraryPage struct {
+	Total  int           `json:"total"`
+	Offset int           `json:"offset"`
+	Limit  int           `json:"limit"`
+	Tracks []store.Track `json:"tracks"`
+}
+
+// SetLibraryScanInterval sets how often the library is rescanned for changes
+// after the initial scan. Zero scans only at startup. It must be called
+// before Broadcast.
+func (r *Radio) SetLibraryScanInterval(d time.Duration) {
+	r.libraryScanInterval = d
+}
+
+// SearchLibrary returns the page of tracks matching q.
+func (r *Radio) SearchLibrary(q LibraryQuery) LibraryPage {
+	words := strings.Fields(searchText(q.Text))
+
+	r.library.mu.RLock()
+	defer r.library.mu.RUnlock()
+
+	page := LibraryPage{Offset: q.Offset, Limit: q.Limit, Tracks: []store.Track{}}
+	for _, t := range r.library.sorted {
+		if q.Channel != "" && t.ChannelID != q.Channel && t.Channel != q.Channel ||
+			q.Artist != "" && !strings.EqualFold(t.Artist, q.Artist) ||
+			q.Album != "" && !strings.EqualFold(t.Album, q.Album) ||
+			q.Genre != "" && !strings.EqualFold(t.Genre, q.Genre) {
+			continue
+		}
+		if !containsAll(t.text, words) {
+			continue
+		}
+		if page.Total >= q.Offset && len(page.Tracks) < q.Limit {
+			page.Tracks = append(page.Tracks, t.Track)
+		}
+		page.Total++
+	}
+	return page
+}
+
+func containsAll(text string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// runLibrary scans the library at startup and then at the configured
+// interval until ctx is cancelled.
+func (r *Radio) runLibrary(ctx context.Context) {
+	var tick <-chan time.Time
+	if r.libraryScanInterval > 0 {
+		ticker := time.NewTicker(r.libraryScanInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if err := r.ScanLibrary(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("library scan failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		}
+	}
+}
+
+// ScanLibrary brings the track index up to date with the data directory.
+// Only new and changed files are read; the index is kept in the store so
+// restarts do not read everything again.
+func (r *Radio) ScanLibrary(ctx context.Context) error {
+	started := time.Now()
+
+	if err := r.loadLibrary(); err != nil {
+		return err
+	}
+
+	r.library.mu.RLock()
+	known := maps.Clone(r.library.tracks)
+	r.library.mu.RUnlock()
+
+	var changed []store.Track
+	seen := make(map[string]bool, len(known))
+	err := filepath.WalkDir(r.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if path != r.dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".mp3") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil {
+			return err
+		}
+		channel, _, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		if !ok {
+			// Files directly in the data directory belong to no channel.
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+
+		_, cueModTime := findCueSheet(path)
+		if t, ok := known[rel]; ok && t.Size == stat.Size() && t.ModTime.Equal(stat.ModTime()) && t.CueModTime.Equal(cueModTime) {
+			return nil
+		}
+
+		t := r.indexTrack(path, stat)
+		t.CueModTime = cueModTime
+		t.Path, t.Channel, t.ChannelID = rel, channel, channelID(channel)
+		changed = append(changed, t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var deleted []string
+	for path := range known {
+		if !seen[path] {
+			deleted = append(deleted, path)
+		}
+	}
+
+	if len(changed) == 0 && len(deleted) == 0 {
+		return nil
+	}
+
+	if r.store != nil {
+		if err := r.store.UpdateTracks(changed, deleted); err != nil {
+			return fmt.Errorf("failed to save library: %w", err)
+		}
+	}
+
+	r.library.mu.Lock()
+	for _, t := range changed {
+		r.library.tracks[t.Path] = t
+	}
+	for _, path := range deleted {
+		delete(r.library.tracks, path)
+	}
+	r.library.sort()
+	total := len(r.library.tracks)
+	r.library.mu.Unlock()
+
+	slog.Info("library scanned", "changed", len(changed), "removed", len(deleted), "tracks", total, "took", time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// loadLibrary reads the saved index the first time it is needed.
+func (r *Radio) loadLibrary() error {
+	r.library.mu.Lock()
+	defer r.library.mu.Unlock()
+	if r.library.loaded {
+		return nil
+	}
+
+	r.library.tracks = make(map[string]store.Track)
+	if r.store != nil {
+		tracks, err := r.store.Tracks()
+		if err != nil {
+			return fmt.Errorf("failed to load library: %w", err)
+		}
+		for _, t := range tracks {
+			r.library.tracks[t.Path] = t
+		}
+	}
+	r.library.sort()
+	r.library.loaded = true
+	return nil
+}
+
+// sort must be called with mu held.
+func (l *library) sort() {
+	l.sorted = l.sorted[:0]
+	for _, t := range l.tracks {
+		parts := t.Parts
+		t.Parts = nil
+		l.sorted = append(l.sorted, newIndexedTrack(t))
+		for _, p := range parts {
+			p.Path, p.Channel, p.ChannelID = t.Path, t.Channel, t.ChannelID
+			p.Size, p.ModTime, p.Hash, p.IndexedAt = t.Size, t.ModTime, t.Hash, t.IndexedAt
+			p.Bitrate, p.SampleRate, p.Genre, p.Year = t.Bitrate, t.SampleRate, t.Genre, t.Year
+			p.Album = cmp.Or(p.Album, t.Album)
+			l.sorted = append(l.sorted, newIndexedTrack(p))
+		}
+	}
+	slices.SortFunc(l.sorted, func(a, b indexedTrack) int {
+		return cmp.Or(
+			cmp.Compare(strings.ToLower(a.Artist), strings.ToLower(b.Artist)),
+			cmp.Compare(strings.ToLower(a.Album), strings.ToLower(b.Album)),
+			cmp.Compare(trackNumber(a.Number), trackNumber(b.Number)),
+			cmp.Compare(a.Part, b.Part),
+			cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)),
+			cmp.Compare(a.Path, b.Path),
+		)
+	})
+}
+
+// trackNumber parses track numbers such as "3" or "3/12".
+func trackNumber(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(strings.SplitN(s, "/", 2)[0]))
+	return n
+}
+
+// indexTrack reads a file's hash, audio properties and tags. Files that are
+// not valid MP3 audio are still indexed, with Error set.
+func (r *Radio) indexTrack(path string, stat fs.FileInfo) store.Track {
+	t := store.Track{
+		Size:      stat.Size(),
+		ModTime:   stat.ModTime(),
+		IndexedAt: time.Now(),
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	t.Hash = hex.EncodeToString(hash.Sum(nil)[:16])
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	info, err := mp3.Scan(file)
+	if err != nil {
+		t.Error = err.Error()
+	} else {
+		t.Duration = info.Duration.Seconds()
+		t.Bitrate = int(math.Round(info.ByteRate() * 8 / 1000))
+		t.SampleRate = info.SampleRate
+		r.trackInfos.put(path, info, t.Size, t.ModTime)
+
+		if t.Parts, err = r.libraryParts(path, info.Duration); err != nil {
+			slog.Warn("ignoring cue sheet", "track", path, "error", err)
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return t
+	}
+	tag, err := id3.Read(file)
+	if errors.Is(err, id3.ErrNoTag) {
+		tag, err = id3.ReadV1(file)
+	}
+	if err == nil {
+		t.Title, t.Artist, t.Album = tag.Title, tag.Artist, tag.Album
+		t.Genre, t.Year, t.Number = tag.Genre, tag.Year, tag.Track
+	}
+
+	return t
+}
@@ -0,0 +1,203 @@
+package radio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Pertsaa/go-radio/internal/store"
+)
+
+// newLibraryRadio returns a radio backed by a store whose data directory
+// holds a jazz and a rock channel.
+func newLibraryRadio(t *testing.T) *Radio {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"jazz/blue in green.mp3", "jazz/so what.mp3", "jazz/café au lait.mp3", "rock/so long.mp3"} {
+		writeTrack(t, filepath.Join(dir, filepath.Dir(name)), filepath.Base(name), 10)
+	}
+
+	s, err := store.Open(filepath.Join(t.TempDir(), store.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	r := New(dir)
+	r.SetStore(s)
+	return r
+}
+
+func libraryPaths(tracks []store.Track) []string {
+	var paths []string
+	for _, t := range tracks {
+		paths = append(paths, filepath.ToSlash(t.Path))
+	}
+	return paths
+}
+
+func TestScanLibrary(t *testing.T) {
+	r := newLibraryRadio(t)
+	// None of these are channel tracks.
+	writeTrack(t, r.dir, "loose.mp3", 10)
+	writeTrack(t, filepath.Join(r.dir, ".recordings", "jazz"), "old show.mp3", 10)
+	if err := os.WriteFile(filepath.Join(r.dir, "jazz", "cover.jpg"), []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, "rock", "broken.mp3"), []byte("not audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.ScanLibrary(context.Background()); err != nil {
+		t.Fatalf("ScanLibrary() error = %v", err)
+	}
+
+	page := r.SearchLibrary(LibraryQuery{Limit: 10})
+	want := []string{"jazz/blue in green.mp3", "jazz/café au lait.mp3", "jazz/so what.mp3", "rock/broken.mp3", "rock/so long.mp3"}
+	if got := libraryPaths(page.Tracks); !slices.Equal(got, want) {
+		t.Fatalf("indexed %v, want %v", got, want)
+	}
+
+	jazz := page.Tracks[0]
+	if jazz.Channel != "jazz" || jazz.ChannelID != channelID("jazz") {
+		t.Errorf("channel = %q (%s), want jazz", jazz.Channel, jazz.ChannelID)
+	}
+	if want := framesDuration(10).Seconds(); jazz.Duration != want || jazz.SampleRate != 44100 || jazz.Hash == "" {
+		t.Errorf("track = %+v, want a hashed %vs track at 44.1 kHz", jazz, want)
+	}
+	if broken := page.Tracks[3]; broken.Error == "" {
+		t.Errorf("broken track has no error")
+	}
+}
+
+func TestScanLibraryChanges(t *testing.T) {
+	r := newLibraryRadio(t)
+	ctx := context.Background()
+	if err := r.ScanLibrary(ctx); err != nil {
+		t.Fatalf("ScanLibrary() error = %v", err)
+	}
+	before := r.SearchLibrary(LibraryQuery{Text: "blue", Limit: 1}).Tracks[0]
+
+	writeTrack(t, filepath.Join(r.dir, "jazz"), "so what.mp3", 20)
+	if err := os.Remove(filepath.Join(r.dir, "rock", "so long.mp3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ScanLibrary(ctx); err != nil {
+		t.Fatalf("ScanLibrary() error = %v", err)
+	}
+
+	page := r.SearchLibrary(LibraryQuery{Limit: 10})
+	want := []string{"jazz/blue in green.mp3", "jazz/café au lait.mp3", "jazz/so what.mp3"}
+	if got := libraryPaths(page.Tracks); !slices.Equal(got, want) {
+		t.Fatalf("indexed %v, want %v", got, want)
+	}
+	if changed := page.Tracks[2]; changed.Duration != framesDuration(20).Seconds() {
+		t.Errorf("changed track duration = %v, want %v", changed.Duration, framesDuration(20).Seconds())
+	}
+	if unchanged := page.Tracks[0]; !unchanged.IndexedAt.Equal(before.IndexedAt) {
+		t.Errorf("unchanged track indexed again at %v", unchanged.IndexedAt)
+	}
+
+	// A restart loads the index from the store.
+	restarted := New(r.dir)
+	restarted.SetStore(r.store)
+	if err := restarted.loadLibrary(); err != nil {
+		t.Fatalf("loadLibrary() error = %v", err)
+	}
+	if got := libraryPaths(restarted.SearchLibrary(LibraryQuery{Limit: 10}).Tracks); !slices.Equal(got, want) {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+}
+
+func TestSearchLibrary(t *testing.T) {
+	r := newLibraryRadio(t)
+	if err := r.ScanLibrary(context.Background()); err != nil {
+		t.Fatalf("ScanLibrary() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query LibraryQuery
+		total int
+		want  []string
+	}{
+		{
+			name:  "first page",
+			query: LibraryQuery{Limit: 2},
+			total: 4,
+			want:  []string{"jazz/blue in green.mp3", "jazz/café au lait.mp3"},
+		},
+		{
+			name:  "second page",
+			query: LibraryQuery{Offset: 2, Limit: 2},
+			total: 4,
+			want:  []string{"jazz/so what.mp3", "rock/so long.mp3"},
+		},
+		{
+			name:  "past the end",
+			query: LibraryQuery{Offset: 4, Limit: 2},
+			total: 4,
+		},
+		{
+			name:  "every word",
+			query: LibraryQuery{Text: "So  WHAT", Limit: 10},
+			total: 1,
+			want:  []string{"jazz/so what.mp3"},
+		},
+		{
+			name:  "without accents",
+			query: LibraryQuery{Text: "cafe", Limit: 10},
+			total: 1,
+			want:  []string{"jazz/café au lait.mp3"},
+		},
+		{
+			name:  "channel name",
+			query: LibraryQuery{Text: "so", Channel: "jazz", Limit: 10},
+			total: 1,
+			want:  []string{"jazz/so what.mp3"},
+		},
+		{
+			name:  "channel ID",
+			query: LibraryQuery{Channel: channelID("rock"), Limit: 10},
+			total: 1,
+			want:  []string{"rock/so long.mp3"},
+		},
+		{
+			name:  "paged search",
+			query: LibraryQuery{Text: "so", Offset: 1, Limit: 1},
+			total: 2,
+			want:  []string{"rock/so long.mp3"},
+		},
+		{
+			name:  "no match",
+			query: LibraryQuery{Text: "bossa", Limit: 10},
+			total: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := r.SearchLibrary(tt.query)
+			if page.Total != tt.total || page.Offset != tt.query.Offset || page.Limit != tt.query.Limit {
+				t.Errorf("page total %d, offset %d, limit %d, want %d, %d, %d",
+					page.Total, page.Offset, page.Limit, tt.total, tt.query.Offset, tt.query.Limit)
+			}
+			if page.Tracks == nil {
+				t.Error("Tracks = nil, want an empty list")
+			}
+			if got := libraryPaths(page.Tracks); !slices.Equal(got, tt.want) {
+				t.Errorf("tracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackNumber(t *testing.T) {
+	for s, want := range map[string]int{"3": 3, "3/12": 3, " 7 ": 7, "": 0, "A1": 0} {
+		if got := trackNumber(s); got != want {
+			t.Errorf("trackNumber(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
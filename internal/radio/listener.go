@@ -0,0 +1,316 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits caps the number of concurrent listeners. A zero value means unlimited.
+type Limits struct {
+	MaxListeners        int
+	MaxChannelListeners int
+	MaxListenersPerAddr int
+}
+
+// DisconnectReason tells why a listener session ended.
+type DisconnectReason string
+
+const (
+	ReasonClientClosed   DisconnectReason = "client_closed"
+	ReasonSlowConsumer   DisconnectReason = "slow_consumer"
+	ReasonServerShutdown DisconnectReason = "server_shutdown"
+	ReasonKicked         DisconnectReason = "kicked"
+)
+
+// Listener is a single listening session on a channel.
+type Listener struct {
+	ID            string
+	ChannelID     string
+	Addr          string
+	UserAgent     string
+	ConnectedAt   time.Time
+	bytesSent     atomic.Int64
+	chunksDropped atomic.Int64
+	queue         *listenerQueue
+	// shifted listeners play from the time-shift buffer instead of the
+	// live queue.
+	shifted   atomic.Bool
+	closed    chan struct{}
+	closeOnce sync.Once
+	reason    atomic.Pointer[DisconnectReason]
+}
+
+// Session is a snapshot of a listener session passed to session hooks.
+type Session struct {
+	ListenerID    string
+	ChannelID     string
+	Addr          string
+	UserAgent     string
+	ConnectedAt   time.Time
+	EndedAt       time.Time
+	BytesSent     int64
+	ChunksDropped int64
+	Reason        DisconnectReason
+}
+
+type SessionHook func(Session)
+
+func (l *Listener) countBytes(n int) {
+	l.bytesSent.Add(int64(n))
+}
+
+// disconnect ends the session from the server side.
+func (l *Listener) disconnect(reason DisconnectReason) {
+	l.end(reason)
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+}
+
+// end records why the session ended. Only the first reason is kept.
+func (l *Listener) end(reason DisconnectReason) {
+	l.reason.CompareAndSwap(nil, &reason)
+}
+
+func (l *Listener) session() Session {
+	s := Session{
+		ListenerID:    l.ID,
+		ChannelID:     l.ChannelID,
+		Addr:          l.Addr,
+		UserAgent:     l.UserAgent,
+		ConnectedAt:   l.ConnectedAt,
+		BytesSent:     l.bytesSent.Load(),
+		ChunksDropped: l.chunksDropped.Load(),
+	}
+	if reason := l.reason.Load(); reason != nil {
+		s.Reason = *reason
+	}
+	return s
+}
+
+func (r *Radio) SetLimits(limits Limits) {
+	r.listenerMux.Lock()
+	defer r.listenerMux.Unlock()
+	r.limits = limits
+}
+
+// OnSessionStart registers a hook called after a listener joins.
+func (r *Radio) OnSessionStart(fn SessionHook) {
+	r.hookMux.Lock()
+	defer r.hookMux.Unlock()
+	r.startHooks = append(r.startHooks, fn)
+}
+
+// OnSessionEnd registers a hook called after a listener leaves.
+func (r *Radio) OnSessionEnd(fn SessionHook) {
+	r.hookMux.Lock()
+	defer r.hookMux.Unlock()
+	r.endHooks = append(r.endHooks, fn)
+}
+
+func (r *Radio) runHooks(hooks *[]SessionHook, s Session) {
+	r.hookMux.Lock()
+	fns := *hooks
+	r.hookMux.Unlock()
+
+	for _, fn := range fns {
+		fn(s)
+	}
+}
+
+// Join registers a new listener on a channel. Limits are enforced unless
+// bypass is set. The returned listener must be released with Leave.
+func (r *Radio) Join(ctx context.Context, channelID, addr, userAgent string, bypass bool) (*Listener, error) {
+	b, ok := r.getBroadcaster(channelID)
+	if !ok {
+		return nil, ErrChannelNotFound
+	}
+	if !b.isRunning() {
+		return nil, ErrChannelOffline
+	}
+
+	r.listenerMux.Lock()
+
+	if !bypass {
+		if err := r.checkLimits(b, addr); err != nil {
+			r.listenerMux.Unlock()
+			return nil, err
+		}
+	}
+
+	l := &Listener{
+		ID:          uuid.NewString(),
+		ChannelID:   channelID,
+		Addr:        addr,
+		UserAgent:   userAgent,
+		ConnectedAt: time.Now(),
+		queue:       newListenerQueue(b.settings.QueueSize),
+		closed:      make(chan struct{}),
+	}
+
+	if r.listenerMap[channelID] == nil {
+		r.listenerMap[channelID] = make(map[string]*Listener)
+	}
+	r.listenerMap[channelID][l.ID] = l
+	b.listenersChanged(len(r.listenerMap[channelID]))
+	r.addrMap[addr]++
+	r.listenerCount++
+	r.stats.join(channelID, len(r.listenerMap[channelID]), r.listenerCount, l.ConnectedAt)
+
+	r.listenerMux.Unlock()
+
+	slog.InfoContext(ctx, "listener joined", "channel", r.channelName(channelID), "listener", l.ID)
+
+	r.runHooks(&r.startHooks, l.session())
+
+	return l, nil
+}
+
+// checkLimits must be called with listenerMux held.
+func (r *Radio) checkLimits(b *broadcaster, addr string) error {
+	if r.limits.MaxListeners > 0 && r.listenerCount >= r.limits.MaxListeners {
+		return &LimitError{Scope: LimitScopeServer, Limit: r.limits.MaxListeners}
+	}
+	maxChannelListeners := r.limits.MaxChannelListeners
+	if b.settings.MaxListeners != 0 {
+		maxChannelListeners = b.settings.MaxListeners
+	}
+	if maxChannelListeners > 0 && len(r.listenerMap[b.channel.ID]) >= maxChannelListeners {
+		return &LimitError{Scope: LimitScopeChannel, Limit: maxChannelListeners}
+	}
+	if r.limits.MaxListenersPerAddr > 0 && r.addrMap[addr] >= r.limits.MaxListenersPerAddr {
+		return &LimitError{Scope: LimitScopeAddr, Limit: r.limits.MaxListenersPerAddr}
+	}
+	return nil
+}
+
+// Leave unregisters a listener. It is safe to call more than once. Sessions
+// that ended without a recorded reason count as closed by the client.
+func (r *Radio) Leave(ctx context.Context, l *Listener) {
+	l.end(ReasonClientClosed)
+
+	r.listenerMux.Lock()
+
+	if _, ok := r.listenerMap[l.ChannelID][l.ID]; !ok {
+		r.listenerMux.Unlock()
+		return
+	}
+
+	delete(r.listenerMap[l.ChannelID], l.ID)
+	if b, ok := r.getBroadcaster(l.ChannelID); ok {
+		b.listenersChanged(len(r.listenerMap[l.ChannelID]))
+	}
+	r.addrMap[l.Addr]--
+	if r.addrMap[l.Addr] <= 0 {
+		delete(r.addrMap, l.Addr)
+	}
+	r.listenerCount--
+
+	session := l.session()
+	session.EndedAt = time.Now()
+	r.stats.leave(session)
+
+	r.listenerMux.Unlock()
+
+	slog.InfoContext(ctx, "listener left",
+		"channel", r.channelName(l.ChannelID),
+		"listener", l.ID,
+		"reason", session.Reason,
+		"bytes", session.BytesSent,
+		"duration", session.EndedAt.Sub(session.ConnectedAt),
+	)
+
+	r.runHooks(&r.endHooks, session)
+}
+
+// Kick ends a listener session.
+func (r *Radio) Kick(listenerID string) error {
+	r.listenerMux.Lock()
+	defer r.listenerMux.Unlock()
+
+	for _, listeners := range r.listenerMap {
+		if l, ok := listeners[listenerID]; ok {
+			l.disconnect(ReasonKicked)
+			return nil
+		}
+	}
+
+	return ErrListenerNotFound
+}
+
+func (r *Radio) ListenerCount(channelID string) int {
+	r.listenerMux.Lock()
+	defer r.listenerMux.Unlock()
+	return len(r.listenerMap[channelID])
+}
+
+func (r *Radio) TotalListenerCount() int {
+	r.listenerMux.Lock()
+	defer r.listenerMux.Unlock()
+	return r.listenerCount
+}
+
+func (r *Radio) publish(b *broadcaster, chunk AudioChunk) {
+	r.listenerMux.Lock()
+	defer r.listenerMux.Unlock()
+
+	for _, l := range r.listenerMap[b.channel.ID] {
+		if l.shifted.Load() {
+			continue
+		}
+		if dropped := l.queue.push(chunk, b.settings.QueuePolicy); dropped > 0 {
+			l.chunksDropped.Add(int64(dropped))
+		}
+		if b.settings.QueuePolicy == QueueDisconnect && l.queue.lag(chunk.Time) > b.settings.MaxLag {
+			l.disconnect(ReasonSlowConsumer)
+		}
+	}
+
+	b.tap(recorderEvent{data: chunk.Data, at: chunk.Time})
+}
+
+// write sends data to a listener within the write timeout and flushes it.
+func (r *Radio) write(w http.ResponseWriter, l *Listener, data []byte, timeout time.Duration) error {
+	rc := http.NewResponseController(w)
+	if timeout > 0 {
+		// Not every writer supports deadlines; those just block as before.
+		_ = rc.SetWriteDeadline(time.Now().Add(timeout))
+	}
+
+	n, err := w.Write(data)
+	l.countBytes(n)
+	if err == nil {
+		err = rc.Flush()
+		if errors.Is(err, http.ErrNotSupported) {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			l.end(ReasonSlowConsumer)
+		} else {
+			l.end(ReasonClientClosed)
+		}
+	}
+
+	return err
+}
+
+// endReason tells why a stream stopped when its context or the radio is done.
+func (r *Radio) endReason() DisconnectReason {
+	select {
+	case <-r.done:
+		return ReasonServerShutdown
+	default:
+		return ReasonClientClosed
+	}
+}
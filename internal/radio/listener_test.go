@@ -0,0 +1,180 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newTestRadio returns a radio with the given channels marked as running
+// without an actual broadcast loop, which is enough for listeners to join.
+func newTestRadio(t *testing.T, channelIDs ...string) *Radio {
+	t.Helper()
+	r := New(t.TempDir())
+	for _, id := range channelIDs {
+		channel := Channel{ID: id, Name: id}
+		b := newBroadcaster(channel, r.settingsFor(channel))
+		b.setRunning(true)
+		r.broadcasterMap[id] = b
+		r.channels = append(r.channels, channel)
+	}
+	return r
+}
+
+func TestJoinLimits(t *testing.T) {
+	type join struct {
+		channel, addr string
+	}
+
+	tests := []struct {
+		name   string
+		limits Limits
+		// channelMax is the max_listeners setting of channel a.
+		channelMax *int
+		joined     []join
+		next       join
+		bypass     bool
+		scope      LimitScope
+	}{
+		{
+			name:   "unlimited",
+			joined: []join{{"a", "10.0.0.1"}, {"a", "10.0.0.1"}, {"b", "10.0.0.2"}},
+			next:   join{"a", "10.0.0.1"},
+		},
+		{
+			name:   "server limit",
+			limits: Limits{MaxListeners: 2},
+			joined: []join{{"a", "10.0.0.1"}, {"b", "10.0.0.2"}},
+			next:   join{"a", "10.0.0.3"},
+			scope:  LimitScopeServer,
+		},
+		{
+			name:   "channel limit",
+			limits: Limits{MaxChannelListeners: 2},
+			joined: []join{{"a", "10.0.0.1"}, {"a", "10.0.0.2"}},
+			next:   join{"a", "10.0.0.3"},
+			scope:  LimitScopeChannel,
+		},
+		{
+			name:   "channel limit on another channel",
+			limits: Limits{MaxChannelListeners: 2},
+			joined: []join{{"a", "10.0.0.1"}, {"a", "10.0.0.2"}},
+			next:   join{"b", "10.0.0.3"},
+		},
+		{
+			name:       "channel setting overrides limit",
+			limits:     Limits{MaxChannelListeners: 1},
+			channelMax: ptr(2),
+			joined:     []join{{"a", "10.0.0.1"}, {"a", "10.0.0.2"}},
+			next:       join{"a", "10.0.0.3"},
+			scope:      LimitScopeChannel,
+		},
+		{
+			name:       "channel setting on another channel",
+			limits:     Limits{MaxChannelListeners: 1},
+			channelMax: ptr(2),
+			joined:     []join{{"a", "10.0.0.1"}, {"a", "10.0.0.2"}},
+			next:       join{"b", "10.0.0.3"},
+		},
+		{
+			name:       "channel setting lifts limit",
+			limits:     Limits{MaxChannelListeners: 1},
+			channelMax: ptr(0),
+			joined:     []join{{"a", "10.0.0.1"}, {"a", "10.0.0.2"}},
+			next:       join{"a", "10.0.0.3"},
+		},
+		{
+			name:       "channel setting does not lift server limit",
+			limits:     Limits{MaxListeners: 2},
+			channelMax: ptr(0),
+			joined:     []join{{"a", "10.0.0.1"}, {"a", "10.0.0.2"}},
+			next:       join{"a", "10.0.0.3"},
+			scope:      LimitScopeServer,
+		},
+		{
+			name:   "address limit",
+			limits: Limits{MaxListenersPerAddr: 1},
+			joined: []join{{"a", "10.0.0.1"}},
+			next:   join{"b", "10.0.0.1"},
+			scope:  LimitScopeAddr,
+		},
+		{
+			name:   "server limit checked first",
+			limits: Limits{MaxListeners: 1, MaxChannelListeners: 1, MaxListenersPerAddr: 1},
+			joined: []join{{"a", "10.0.0.1"}},
+			next:   join{"a", "10.0.0.1"},
+			scope:  LimitScopeServer,
+		},
+		{
+			name:   "bypass",
+			limits: Limits{MaxListeners: 1},
+			joined: []join{{"a", "10.0.0.1"}},
+			next:   join{"a", "10.0.0.2"},
+			bypass: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRadio(t, "a", "b")
+			b := r.broadcasterMap["a"]
+			b.settings = ChannelSettings{MaxListeners: tt.channelMax}.apply(b.settings)
+			r.SetLimits(tt.limits)
+
+			for _, j := range tt.joined {
+				if _, err := r.Join(context.Background(), j.channel, j.addr, "", false); err != nil {
+					t.Fatalf("Join(%s, %s) error = %v", j.channel, j.addr, err)
+				}
+			}
+
+			_, err := r.Join(context.Background(), tt.next.channel, tt.next.addr, "", tt.bypass)
+			if tt.scope == "" {
+				if err != nil {
+					t.Fatalf("Join() error = %v", err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Scope != tt.scope {
+				t.Fatalf("Join() error = %v, want %s limit", err, tt.scope)
+			}
+			if !errors.Is(err, ErrCapacityReached) {
+				t.Errorf("Join() error does not wrap ErrCapacityReached")
+			}
+		})
+	}
+}
+
+func TestLeaveFreesLimits(t *testing.T) {
+	r := newTestRadio(t, "a")
+	r.SetLimits(Limits{MaxListeners: 1, MaxListenersPerAddr: 1})
+
+	l, err := r.Join(context.Background(), "a", "10.0.0.1", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Leave(context.Background(), l)
+	r.Leave(context.Background(), l)
+
+	if n := r.TotalListenerCount(); n != 0 {
+		t.Errorf("TotalListenerCount() = %d after leaving twice, want 0", n)
+	}
+	if _, err := r.Join(context.Background(), "a", "10.0.0.1", "", false); err != nil {
+		t.Errorf("Join() after Leave error = %v", err)
+	}
+}
+
+func TestJoinOfflineChannel(t *testing.T) {
+	r := newTestRadio(t, "a")
+	r.broadcasterMap["a"].setRunning(false)
+	if _, err := r.Join(context.Background(), "a", "10.0.0.1", "", false); !errors.Is(err, ErrChannelOffline) {
+		t.Errorf("Join() error = %v, want %v", err, ErrChannelOffline)
+	}
+}
+
+func TestJoinUnknownChannel(t *testing.T) {
+	r := New(t.TempDir())
+	if _, err := r.Join(context.Background(), "missing", "10.0.0.1", "", false); !errors.Is(err, ErrChannelNotFound) {
+		t.Errorf("Join() error = %v, want %v", err, ErrChannelNotFound)
+	}
+}
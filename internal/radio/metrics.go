@@ -0,0 +1,55 @@
+package radio
+
+import "time"
+
+type ChannelMetrics struct {
+	ChannelID      string
+	ChannelName    string
+	OnAir          bool
+	Idle           bool
+	Listeners      int
+	BytesSent      int64
+	ChunksDropped  int64
+	Disconnects    map[DisconnectReason]int64
+	BroadcasterLag time.Duration
+	TrackChanges   int64
+	OpenErrors     int64
+	ReadErrors     int64
+	Restarts       int64
+	Quarantined    int
+}
+
+// Metrics returns a snapshot of the counters of every channel.
+func (r *Radio) Metrics() []ChannelMetrics {
+	result := make([]ChannelMetrics, 0, len(r.channels))
+
+	for _, channel := range r.channels {
+		r.listenerMux.Lock()
+		stats := r.channelStats(channel.ID)
+		r.listenerMux.Unlock()
+
+		m := ChannelMetrics{
+			ChannelID:     channel.ID,
+			ChannelName:   channel.Name,
+			Listeners:     stats.Listeners,
+			BytesSent:     stats.BytesSent,
+			ChunksDropped: stats.ChunksDropped,
+			Disconnects:   stats.Disconnects,
+		}
+
+		if b, ok := r.getBroadcaster(channel.ID); ok {
+			m.OnAir = b.isRunning()
+			m.Idle = b.isIdle()
+			m.BroadcasterLag = b.lag()
+			m.TrackChanges = b.trackChanges.Load()
+			m.OpenErrors = b.openErrors.Load()
+			m.ReadErrors = b.readErrors.Load()
+			m.Restarts = b.restarts.Load()
+			m.Quarantined = len(b.quarantined())
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
@@ -0,0 +1,194 @@
+package radio
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/artwork"
+	"github.com/Pertsaa/go-radio/internal/id3"
+)
+
+// trackArtworkNames are looked up next to a track without embedded art.
+var trackArtworkNames = []string{"cover.jpg", "cover.jpeg", "cover.png", "folder.jpg", "folder.jpeg", "folder.png"}
+
+// NowPlaying describes what is on air on a channel. Track is empty while the
+// channel is idle or offline.
+type NowPlaying struct {
+	ChannelID string     `json:"channelId"`
+	Track     string     `json:"track,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Artist    string     `json:"artist,omitempty"`
+	Album     string     `json:"album,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	// Part is the number of the track on air within the file's cue sheet.
+	// The other fields then describe that track rather than the file.
+	Part int `json:"part,omitempty"`
+	// Duration is the length of the track in seconds, when known.
+	Duration float64 `json:"duration,omitempty"`
+	// Artwork is the path of the track's cover on this server, falling back
+	// to the channel's artwork.
+	Artwork   string `json:"artwork,omitempty"`
+	Listeners int    `json:"listeners"`
+}
+
+// trackMeta is what the radio shows about a track besides its file name.
+type trackMeta struct {
+	Title  string
+	Artist string
+	Album  string
+	// artwork is the hash of the track's cover in the artwork cache.
+	artwork string
+}
+
+// trackMeta describes the track at path from its tags and cover, with the
+// overrides from its sidecar files applied.
+func (r *Radio) trackMeta(path string) (trackMeta, trackOverride, error) {
+	meta, tagErr := r.taggedMeta(path)
+	o, err := r.trackOverride(path)
+	if err == nil {
+		err = r.applyOverride(&meta, path, o)
+	}
+	return meta, o, errors.Join(tagErr, err)
+}
+
+// taggedMeta reads the tags and cover of the track at path. The cover is
+// taken from the tag, or from an image in the track's directory. Results are
+// cached until the file changes.
+func (r *Radio) taggedMeta(path string) (trackMeta, error) {
+	return r.trackMetas.get(path, func(file *os.File) (trackMeta, error) {
+		var meta trackMeta
+		var cover []byte
+
+		tag, err := id3.Read(file)
+		switch {
+		case err == nil:
+			meta.Title, meta.Artist, meta.Album = tag.Title, tag.Artist, tag.Album
+			if pic, ok := tag.Cover(); ok {
+				cover = pic.Data
+			}
+		case !errors.Is(err, id3.ErrNoTag):
+			return meta, err
+		}
+
+		if cover == nil {
+			cover, err = readTrackArtwork(filepath.Dir(path))
+			if err != nil {
+				return meta, err
+			}
+		}
+		if cover != nil {
+			// Unsupported images are left out rather than failing the track.
+			if img, err := r.artwork.Prepare(cover); err == nil {
+				meta.artwork = img.Hash
+			}
+		}
+
+		return meta, nil
+	})
+}
+
+func readTrackArtwork(dir string) ([]byte, error) {
+	for _, name := range trackArtworkNames {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return data, err
+	}
+	return nil, nil
+}
+
+// NowPlaying returns the track on air on a channel.
+func (r *Radio) NowPlaying(channelID string) (NowPlaying, error) {
+	b, ok := r.getBroadcaster(channelID)
+	if !ok {
+		return NowPlaying{}, ErrChannelNotFound
+	}
+	channel, _ := r.GetChannel(channelID)
+
+	np := NowPlaying{
+		ChannelID: channelID,
+		Artwork:   channel.Artwork,
+		Listeners: r.ListenerCount(channelID),
+	}
+
+	b.mu.Lock()
+	path, startedAt, part, partAt := b.trackPath, b.trackAt, b.part, b.partAt
+	b.mu.Unlock()
+	if path == "" {
+		return np, nil
+	}
+
+	np.Track = filepath.Base(path)
+	np.Title = strings.TrimSuffix(np.Track, filepath.Ext(np.Track))
+	np.StartedAt = &startedAt
+
+	meta, o, err := r.trackMeta(path)
+	if err != nil {
+		r.logger(channel).Debug("failed to read track metadata", "track", np.Track, "error", err)
+	}
+	if meta.Title != "" {
+		np.Title = meta.Title
+	}
+	np.Artist, np.Album = meta.Artist, meta.Album
+	if meta.artwork != "" {
+		np.Artwork = "/radio/artwork/" + meta.artwork
+	}
+
+	if info, err := r.trackInfo(path); err == nil {
+		in, out := o.cues(info.Duration)
+		np.Duration = (out - in).Seconds()
+	}
+
+	if part != nil {
+		np.Part = part.Number
+		np.Title = cmp.Or(part.Title, np.Title)
+		np.Artist = cmp.Or(part.Artist, np.Artist)
+		np.Album = cmp.Or(part.Album, np.Album)
+		np.StartedAt = &partAt
+		np.Duration = (part.End - part.Start).Seconds()
+	}
+
+	return np, nil
+}
+
+// Artwork returns a cached cover image by hash, scaled to fit size pixels,
+// which is rounded up to one of artwork.Sizes. Zero selects the original.
+func (r *Radio) Artwork(hash string, size int) (artwork.Image, error) {
+	img, err := r.artwork.Get(hash, artwork.FitSize(size))
+	if errors.Is(err, artwork.ErrNotFound) {
+		return artwork.Image{}, ErrArtworkNotFound
+	}
+	return img, err
+}
+
+// trackStarted records the track now on air and passes the change on to
+// recordings and the time-shift buffer.
+func (b *broadcaster) trackStarted(path string, at time.Time) {
+	name := filepath.Base(path)
+
+	b.mu.Lock()
+	b.track, b.trackPath, b.trackAt = name, path, at
+	b.part = nil
+	b.mu.Unlock()
+
+	if b.shift != nil {
+		b.shift.trackStarted(name, at)
+	}
+
+	b.tap(recorderEvent{track: name, at: at})
+}
+
+func (b *broadcaster) currentTrack() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.part != nil {
+		return b.part.label()
+	}
+	return b.track
+}
@@ -0,0 +1,92 @@
+package radio
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/mp3"
+	"github.com/Pertsaa/go-radio/internal/store"
+)
+
+const (
+	// playbackSaveInterval is how often the playback position is saved while
+	// a track plays.
+	playbackSaveInterval = 5 * time.Second
+	// frameSearchSize is how far past a saved offset a frame boundary is
+	// looked for when resuming.
+	frameSearchSize = 8 * 1024
+)
+
+// resumePosition returns the rotation index and byte offset to resume the
+// channel from. A track that changed since it was saved restarts from the
+// beginning, and a track that disappeared is replaced by the one now at its
+// place in the rotation.
+func (r *Radio) resumePosition(b *broadcaster, sources []AudioSource) (int, int64) {
+	if r.store == nil {
+		return 0, 0
+	}
+
+	logger := r.logger(b.channel)
+
+	p, ok, err := r.store.Playback(b.channel.ID)
+	if err != nil {
+		logger.Error("failed to load playback position", "error", err)
+		return 0, 0
+	}
+	if !ok {
+		return 0, 0
+	}
+
+	index := -1
+	for i, source := range sources {
+		if source.Name == p.Track {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		index = p.Index % len(sources)
+		logger.Info("saved track is gone, resuming rotation", "saved_track", p.Track, "track", sources[index].Name)
+		return index, 0
+	}
+
+	info, err := os.Stat(filepath.Join(r.channelDir(b.channel), p.Track))
+	if err != nil || info.Size() != p.Size || !info.ModTime().Equal(p.ModTime) {
+		logger.Info("saved track changed, restarting it", "track", p.Track)
+		return index, 0
+	}
+	if p.Offset >= p.Size {
+		return (index + 1) % len(sources), 0
+	}
+
+	logger.Info("resuming playback", "track", p.Track, "offset", p.Offset)
+	return index, p.Offset
+}
+
+func (r *Radio) savePlayback(b *broadcaster, p store.Playback) {
+	if r.store == nil {
+		return
+	}
+	p.SavedAt = time.Now()
+	if err := r.store.SavePlayback(b.channel.ID, p); err != nil {
+		r.logger(b.channel).Error("failed to save playback position", "track", p.Track, "error", err)
+	}
+}
+
+// seekFrame moves file to the first frame boundary at or after offset and
+// returns the new offset. Files without a recognizable frame there are
+// positioned at offset itself.
+func seekFrame(file *os.File, offset int64) (int64, error) {
+	buf := make([]byte, frameSearchSize)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if i := mp3.Sync(buf[:n]); i > 0 {
+		offset += int64(i)
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	return offset, err
+}
@@ -0,0 +1,131 @@
+package radio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/mp3"
+)
+
+// QueuePolicy decides what happens when a listener's queue is full.
+type QueuePolicy string
+
+const (
+	// QueueDropOldest discards the oldest queued chunk to make room.
+	QueueDropOldest QueuePolicy = "drop_oldest"
+	// QueueSkipToLive discards the whole queue and resumes at the first
+	// frame boundary of the newest chunk.
+	QueueSkipToLive QueuePolicy = "skip_to_live"
+	// QueueDisconnect discards new chunks and disconnects the listener as a
+	// slow consumer once it lags more than Settings.MaxLag.
+	QueueDisconnect QueuePolicy = "disconnect"
+)
+
+func (p QueuePolicy) Valid() bool {
+	switch p {
+	case QueueDropOldest, QueueSkipToLive, QueueDisconnect:
+		return true
+	}
+	return false
+}
+
+// listenerQueue is a bounded queue of chunks waiting to be written to a
+// listener.
+type listenerQueue struct {
+	mu      sync.Mutex
+	chunks  []AudioChunk
+	size    int
+	sending time.Time
+	resync  bool
+	ready   chan struct{}
+}
+
+func newListenerQueue(size int) *listenerQueue {
+	return &listenerQueue{
+		chunks: make([]AudioChunk, 0, size),
+		size:   size,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push adds a chunk, applying policy when the queue is full. It returns the
+// number of chunks dropped.
+func (q *listenerQueue) push(chunk AudioChunk, policy QueuePolicy) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := 0
+	if len(q.chunks) >= q.size {
+		switch policy {
+		case QueueSkipToLive:
+			dropped = len(q.chunks)
+			clear(q.chunks)
+			q.chunks = q.chunks[:0]
+			q.resync = true
+		case QueueDisconnect:
+			return 1
+		default:
+			dropped = 1
+			q.chunks[0] = AudioChunk{}
+			q.chunks = append(q.chunks[:0], q.chunks[1:]...)
+		}
+	}
+
+	if q.resync {
+		// Start on a frame boundary so decoders pick the stream up cleanly.
+		offset := mp3.Sync(chunk.Data)
+		if offset < 0 {
+			return dropped + 1
+		}
+		chunk.Data = chunk.Data[offset:]
+		q.resync = false
+	}
+
+	q.chunks = append(q.chunks, chunk)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+
+	return dropped
+}
+
+// pop removes the oldest chunk. The chunk counts towards the lag until sent
+// is called.
+func (q *listenerQueue) pop() (AudioChunk, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.chunks) == 0 {
+		return AudioChunk{}, false
+	}
+
+	chunk := q.chunks[0]
+	q.chunks[0] = AudioChunk{}
+	q.chunks = append(q.chunks[:0], q.chunks[1:]...)
+	q.sending = chunk.Time
+
+	return chunk, true
+}
+
+func (q *listenerQueue) sent() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sending = time.Time{}
+}
+
+// lag returns how long the oldest undelivered chunk has been waiting.
+func (q *listenerQueue) lag(now time.Time) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	oldest := q.sending
+	if oldest.IsZero() && len(q.chunks) > 0 {
+		oldest = q.chunks[0].Time
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return now.Sub(oldest)
+}
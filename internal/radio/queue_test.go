@@ -0,0 +1,158 @@
+package radio
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+)
+
+// frameHeader is the start of an MPEG-1 Layer III frame that mp3.Sync finds.
+var frameHeader = []byte{0xFF, 0xFB, 0x90, 0x00}
+
+// taggedChunk returns a chunk whose data is two tag bytes followed by the
+// start of a frame, so it can be recognised after being queued.
+func taggedChunk(tag byte) AudioChunk {
+	return AudioChunk{Data: append([]byte{tag, tag}, frameHeader...)}
+}
+
+// queuedTags pops every queued chunk and returns their tags.
+func queuedTags(q *listenerQueue) []byte {
+	var tags []byte
+	for {
+		chunk, ok := q.pop()
+		if !ok {
+			return tags
+		}
+		tags = append(tags, chunk.Data[0])
+	}
+}
+
+func TestListenerQueuePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  QueuePolicy
+		pushes  int
+		dropped []int
+		want    []byte
+	}{
+		{name: "drop oldest below size", policy: QueueDropOldest, pushes: 3, dropped: []int{0, 0, 0}, want: []byte{1, 2, 3}},
+		{name: "drop oldest", policy: QueueDropOldest, pushes: 5, dropped: []int{0, 0, 0, 1, 1}, want: []byte{3, 4, 5}},
+		{name: "disconnect", policy: QueueDisconnect, pushes: 5, dropped: []int{0, 0, 0, 1, 1}, want: []byte{1, 2, 3}},
+		{name: "unknown policy drops oldest", policy: "", pushes: 4, dropped: []int{0, 0, 0, 1}, want: []byte{2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newListenerQueue(3)
+			var dropped []int
+			for tag := 1; tag <= tt.pushes; tag++ {
+				dropped = append(dropped, q.push(taggedChunk(byte(tag)), tt.policy))
+			}
+			if !slices.Equal(dropped, tt.dropped) {
+				t.Errorf("dropped = %v, want %v", dropped, tt.dropped)
+			}
+			if got := queuedTags(q); !bytes.Equal(got, tt.want) {
+				t.Errorf("queued = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenerQueueSkipToLive(t *testing.T) {
+	q := newListenerQueue(3)
+	for tag := byte(1); tag <= 3; tag++ {
+		q.push(taggedChunk(tag), QueueSkipToLive)
+	}
+
+	// The chunk that overflows the queue replaces it, cut to its first frame.
+	if dropped := q.push(taggedChunk(4), QueueSkipToLive); dropped != 3 {
+		t.Errorf("dropped = %d, want 3", dropped)
+	}
+	chunk, _ := q.pop()
+	if !bytes.Equal(chunk.Data, frameHeader) {
+		t.Errorf("resynced chunk = %x, want %x", chunk.Data, frameHeader)
+	}
+
+	// Once in sync, chunks are queued whole.
+	q.push(taggedChunk(5), QueueSkipToLive)
+	chunk, _ = q.pop()
+	if !bytes.Equal(chunk.Data, taggedChunk(5).Data) {
+		t.Errorf("chunk = %x, want it unchanged", chunk.Data)
+	}
+}
+
+func TestListenerQueueSkipToLiveWithoutFrame(t *testing.T) {
+	q := newListenerQueue(1)
+	q.push(taggedChunk(1), QueueSkipToLive)
+
+	// Chunks without a frame are dropped until one arrives.
+	if dropped := q.push(AudioChunk{Data: []byte("no frame")}, QueueSkipToLive); dropped != 2 {
+		t.Errorf("dropped = %d, want 2", dropped)
+	}
+	if got := queuedTags(q); len(got) != 0 {
+		t.Errorf("queued = %v, want nothing", got)
+	}
+	if dropped := q.push(AudioChunk{Data: []byte("still none")}, QueueSkipToLive); dropped != 1 {
+		t.Errorf("dropped = %d, want 1", dropped)
+	}
+
+	q.push(taggedChunk(2), QueueSkipToLive)
+	chunk, _ := q.pop()
+	if !bytes.Equal(chunk.Data, frameHeader) {
+		t.Errorf("resynced chunk = %x, want %x", chunk.Data, frameHeader)
+	}
+}
+
+func TestListenerQueueReady(t *testing.T) {
+	q := newListenerQueue(2)
+	select {
+	case <-q.ready:
+		t.Fatal("ready before a push")
+	default:
+	}
+
+	q.push(taggedChunk(1), QueueDropOldest)
+	q.push(taggedChunk(2), QueueDropOldest)
+	select {
+	case <-q.ready:
+	default:
+		t.Fatal("not ready after a push")
+	}
+	select {
+	case <-q.ready:
+		t.Fatal("ready signalled once per push instead of coalescing")
+	default:
+	}
+}
+
+func TestListenerQueueLag(t *testing.T) {
+	q := newListenerQueue(3)
+	now := time.Now()
+
+	if lag := q.lag(now); lag != 0 {
+		t.Errorf("empty lag = %v, want 0", lag)
+	}
+
+	q.push(AudioChunk{Data: frameHeader, Time: now.Add(-8 * time.Second)}, QueueDropOldest)
+	q.push(AudioChunk{Data: frameHeader, Time: now.Add(-6 * time.Second)}, QueueDropOldest)
+	if lag := q.lag(now); lag != 8*time.Second {
+		t.Errorf("lag = %v, want 8s", lag)
+	}
+
+	// A chunk being written still counts until it is sent.
+	q.pop()
+	if lag := q.lag(now); lag != 8*time.Second {
+		t.Errorf("lag while sending = %v, want 8s", lag)
+	}
+	q.sent()
+	if lag := q.lag(now); lag != 6*time.Second {
+		t.Errorf("lag after sending = %v, want 6s", lag)
+	}
+
+	q.pop()
+	q.sent()
+	if lag := q.lag(now); lag != 0 {
+		t.Errorf("drained lag = %v, want 0", lag)
+	}
+}
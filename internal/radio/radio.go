@@ -1,30 +1,86 @@
 package radio
 
 import (
-	"fmt"
-	"io"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Pertsaa/go-radio/internal/artwork"
+	"github.com/Pertsaa/go-radio/internal/cue"
+	"github.com/Pertsaa/go-radio/internal/mp3"
+	"github.com/Pertsaa/go-radio/internal/store"
 	"github.com/google/uuid"
 )
 
+// channelNamespace derives channel IDs from directory names so they stay the
+// same across restarts.
+var channelNamespace = uuid.MustParse("0b6bd0c4-0f4e-4a57-9a0e-3c1f8d6f4a21")
+
+// channelID returns the ID of the channel in the named directory.
+func channelID(name string) string {
+	return uuid.NewSHA1(channelNamespace, []byte(name)).String()
+}
+
 type Radio struct {
-	dir            string
-	channels       []Channel
-	broadcasterMap map[string]chan AudioChunk
-	broadcasterMux sync.Mutex
-	bufferMap      map[string]*RingBuffer
-	bufferMux      sync.Mutex
+	dir                 string
+	settings            Settings
+	channelSettings     map[string]ChannelSettings
+	channels            []Channel
+	broadcasterMap      map[string]*broadcaster
+	broadcasterMux      sync.Mutex
+	limits              Limits
+	listenerMap         map[string]map[string]*Listener
+	addrMap             map[string]int
+	listenerCount       int
+	listenerMux         sync.Mutex
+	stats               statsRecorder
+	startHooks          []SessionHook
+	endHooks            []SessionHook
+	hookMux             sync.Mutex
+	done                <-chan struct{}
+	store               *store.Store
+	trackInfos          fileCache[mp3.Info]
+	trackMetas          fileCache[trackMeta]
+	trackLists          fileCache[map[string]trackOverride]
+	sidecars            fileCache[trackOverride]
+	coverHashes         fileCache[string]
+	cueSheets           fileCache[*cue.Sheet]
+	library             library
+	libraryScanInterval time.Duration
+	recordingOpts       RecordingOptions
+	recorders           map[string]*recorder
+	artwork             *artwork.Cache
+	recordersMux        sync.Mutex
+	pruneMux            sync.Mutex
 }
 
+// Channel is a directory of tracks in the data directory. Its Name is the
+// directory name; the descriptive fields come from an optional channel.json
+// or channel.yaml next to the tracks.
 type Channel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	DisplayName string   `json:"displayName"`
+	Description string   `json:"description,omitempty"`
+	Genre       string   `json:"genre,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Order       int      `json:"order"`
+	Color       string   `json:"color,omitempty"`
+	Default     bool     `json:"default,omitempty"`
+	// Artwork is the path of the channel's cover image on this server,
+	// versioned by its content.
+	Artwork     string             `json:"artwork,omitempty"`
+	Status      ChannelStatus      `json:"status,omitempty"`
+	Idle        bool               `json:"idle,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	Quarantined []QuarantinedTrack `json:"quarantined,omitempty"`
+
+	artworkPath string
 }
 
 type AudioSource struct {
@@ -34,13 +90,35 @@ type AudioSource struct {
 
 type AudioChunk struct {
 	Data []byte
+	// Time is when the chunk was broadcast.
+	Time time.Time
 }
 
 func New(dataDir string) *Radio {
-	return &Radio{dir: dataDir, broadcasterMap: make(map[string]chan AudioChunk), bufferMap: make(map[string]*RingBuffer)}
+	return &Radio{
+		dir:            dataDir,
+		settings:       DefaultSettings(),
+		broadcasterMap: make(map[string]*broadcaster),
+		listenerMap:    make(map[string]map[string]*Listener),
+		addrMap:        make(map[string]int),
+		stats:          newStatsRecorder(),
+		recorders:      make(map[string]*recorder),
+		artwork:        artwork.NewCache(filepath.Join(dataDir, ".cache", "artwork")),
+	}
 }
 
-func (r *Radio) LoadChannels() error {
+// SetStore sets where play history is kept. It must be called before
+// Broadcast.
+func (r *Radio) SetStore(s *store.Store) {
+	r.store = s
+}
+
+// LoadChannels scans the data directory for channels. ctx is the lifetime of
+// the radio; listener sessions still open when it ends are reported as ended
+// by server shutdown.
+func (r *Radio) LoadChannels(ctx context.Context) error {
+	r.done = ctx.Done()
+
 	channels := []Channel{}
 
 	entries, err := os.ReadDir(r.dir)
@@ -49,144 +127,153 @@ func (r *Radio) LoadChannels() error {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
-			channels = append(channels, Channel{ID: uuid.NewString(), Name: entry.Name()})
+		// Hidden directories, such as the recordings, are not channels.
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			channel := Channel{ID: channelID(entry.Name()), Name: entry.Name()}
+			r.describeChannel(&channel)
+			channels = append(channels, channel)
 		}
 	}
+	r.sortChannels(channels)
+
+	r.broadcasterMux.Lock()
+	for _, channel := range channels {
+		b := newBroadcaster(channel, r.settingsFor(channel))
+		r.openTimeShift(b)
+		r.broadcasterMap[channel.ID] = b
+	}
+	r.broadcasterMux.Unlock()
 
 	r.channels = channels
 
 	return nil
 }
 
+// GetChannels returns all channels along with their current broadcast status.
 func (r *Radio) GetChannels() []Channel {
-	return r.channels
+	channels := make([]Channel, 0, len(r.channels))
+	for _, channel := range r.channels {
+		if b, ok := r.getBroadcaster(channel.ID); ok {
+			b.mu.Lock()
+			channel.Status = b.status
+			channel.Idle = b.idle
+			channel.Error = b.lastError
+			b.mu.Unlock()
+			channel.Quarantined = b.quarantined()
+		}
+		channels = append(channels, channel)
+	}
+	return channels
 }
 
-func (r *Radio) Broadcast() {
+func (r *Radio) GetChannel(channelID string) (Channel, bool) {
 	for _, channel := range r.channels {
-		go r.BroadcastChannel(channel)
+		if channel.ID == channelID {
+			return channel, true
+		}
 	}
+	return Channel{}, false
 }
 
-func (r *Radio) BroadcastChannel(channel Channel) {
-	entries, err := os.ReadDir(fmt.Sprintf("%s/%s", r.dir, channel.Name))
-	if err != nil {
-		log.Fatalf("Failed to load audio files: %v", err)
-	}
+func (r *Radio) logger(channel Channel) *slog.Logger {
+	return slog.With("channel", channel.Name)
+}
 
-	audioSources := []AudioSource{}
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".mp3") {
-			audioSources = append(audioSources, AudioSource{ID: uuid.NewString(), Name: entry.Name()})
-		}
-	}
+func (r *Radio) channelName(channelID string) string {
+	channel, _ := r.GetChannel(channelID)
+	return channel.Name
+}
 
-	if len(audioSources) == 0 {
-		log.Printf("No .mp3 audio files found in channel: %s", channel.Name)
-		return
+// Broadcast runs every channel's broadcast loop and blocks until the context
+// is cancelled and all loops have stopped.
+func (r *Radio) Broadcast(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, channel := range r.channels {
+		wg.Go(func() {
+			r.BroadcastChannel(ctx, channel)
+		})
 	}
-
-	r.bufferMux.Lock()
-	audioBuffer := NewRingBuffer(28)
-	r.bufferMap[channel.ID] = audioBuffer
-	r.bufferMux.Unlock()
+	wg.Go(func() {
+		r.runRecordings(ctx)
+	})
+	wg.Go(func() {
+		r.runLibrary(ctx)
+	})
+	wg.Wait()
 
 	r.broadcasterMux.Lock()
-	broadcastChan := make(chan AudioChunk)
-	r.broadcasterMap[channel.ID] = broadcastChan
-	r.broadcasterMux.Unlock()
-
-	const chunkSize = 1024 * 4
-	readBuffer := make([]byte, chunkSize)
-	ticker := time.NewTicker(170 * time.Millisecond)
-	defer ticker.Stop()
-
-	currentFileIndex := 0
-
-	for {
-		fileName := audioSources[currentFileIndex].Name
-		filePath := fmt.Sprintf("%s/%s/%s", r.dir, channel.Name, fileName)
-
-		file, err := os.Open(filePath)
-		if err != nil {
-			log.Fatalf("Failed to open audio file: %v", err)
-		}
-
-		log.Printf("Streaming: %s | %s\n", channel.Name, fileName)
-
-		for range ticker.C {
-			n, err := file.Read(readBuffer)
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("Error reading audio file: %v", err)
-			}
-
-			chunkData := make([]byte, n)
-			copy(chunkData, readBuffer[:n])
-
-			audioBuffer.Write(chunkData)
-
-			chunk := AudioChunk{Data: chunkData}
-
-			select {
-			case broadcastChan <- chunk:
-				// Broadcast successful.
-			default:
-				// Broadcaster channel is full, skip this chunk to avoid blocking.
-			}
-		}
-
-		file.Close()
-
-		currentFileIndex++
-
-		if currentFileIndex >= len(audioSources) {
-			currentFileIndex = 0
+	defer r.broadcasterMux.Unlock()
+	for _, b := range r.broadcasterMap {
+		if b.shift != nil {
+			b.shift.close()
 		}
 	}
 }
 
-func (r *Radio) WriteBuffer(w io.Writer, channelID string) error {
-	r.bufferMux.Lock()
-	audioBuffer, ok := r.bufferMap[channelID]
-	r.bufferMux.Unlock()
+// WriteBuffer sends the channel's buffered audio so playback starts at once.
+func (r *Radio) WriteBuffer(ctx context.Context, w http.ResponseWriter, l *Listener) error {
+	b, ok := r.getBroadcaster(l.ChannelID)
 	if !ok {
-		return fmt.Errorf("channel %s not found", channelID)
+		return ErrChannelNotFound
+	}
+	if !b.isRunning() {
+		return ErrChannelOffline
 	}
 
-	initialChunks := audioBuffer.ReadAll()
-	for _, chunk := range initialChunks {
-		if _, err := w.Write(chunk); err != nil {
+	for _, chunk := range b.buffer.ReadAll() {
+		if ctx.Err() != nil {
+			l.end(r.endReason())
+			return nil
+		}
+		if err := r.write(w, l, chunk, b.settings.WriteTimeout); err != nil {
 			return err
 		}
 	}
 
-	if flusher, ok := w.(http.Flusher); ok {
-		flusher.Flush()
-	}
-
 	return nil
 }
 
-func (r *Radio) StreamChunks(w io.Writer, channelID string) error {
-	r.broadcasterMux.Lock()
-	broadcastChan, ok := r.broadcasterMap[channelID]
-	r.broadcasterMux.Unlock()
+// StreamChunks writes broadcast chunks to w until the session ends. The
+// reason is recorded on the listener for Leave.
+func (r *Radio) StreamChunks(ctx context.Context, w http.ResponseWriter, l *Listener) error {
+	b, ok := r.getBroadcaster(l.ChannelID)
 	if !ok {
-		return fmt.Errorf("channel %s not found", channelID)
+		return ErrChannelNotFound
 	}
 
-	for chunk := range broadcastChan {
-		if _, err := w.Write(chunk.Data); err != nil {
-			return err
+	go func() {
+		select {
+		case <-l.closed:
+			// Fail a write that is blocked on the client so the session
+			// ends right away.
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now())
+		case <-ctx.Done():
 		}
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.end(r.endReason())
+			return nil
+		case <-r.done:
+			l.end(ReasonServerShutdown)
+			return nil
+		case <-l.closed:
+			// The reason was recorded by whoever closed the session.
+			return nil
+		case <-l.queue.ready:
+			for {
+				chunk, ok := l.queue.pop()
+				if !ok {
+					break
+				}
+				err := r.write(w, l, chunk.Data, b.settings.WriteTimeout)
+				l.queue.sent()
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
-
-	return nil
 }
@@ -0,0 +1,613 @@
+package radio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// recorderQueueSize is the number of events buffered per recorder before
+	// chunks are dropped.
+	recorderQueueSize = 256
+	// recordingTimeFormat names recording files after their start time.
+	recordingTimeFormat = "20060102-150405"
+)
+
+// RecordingKind tells how a recording was started.
+type RecordingKind string
+
+const (
+	RecordingManual     RecordingKind = "manual"
+	RecordingScheduled  RecordingKind = "scheduled"
+	RecordingContinuous RecordingKind = "continuous"
+)
+
+// Recording describes a recorded file. The same data is kept next to the
+// audio in a JSON sidecar file.
+type Recording struct {
+	ID        string        `json:"id"`
+	ChannelID string        `json:"channelId"`
+	Channel   string        `json:"channel"`
+	Kind      RecordingKind `json:"kind"`
+	StartedAt time.Time     `json:"startedAt"`
+	EndedAt   *time.Time    `json:"endedAt,omitempty"`
+	Size      int64         `json:"size"`
+	Active    bool          `json:"active"`
+	// Error is set when the recording stopped because of a failure.
+	Error string         `json:"error,omitempty"`
+	Cues  []RecordingCue `json:"cues,omitempty"`
+
+	path string
+}
+
+// RecordingCue marks where a track starts within a recording.
+type RecordingCue struct {
+	Track string `json:"track"`
+	// Offset is the time into the recording in seconds.
+	Offset float64   `json:"offset"`
+	Byte   int64     `json:"byte"`
+	At     time.Time `json:"at"`
+}
+
+// RecordingSchedule records a channel for Duration from Start, a time of day
+// in "15:04" form, either daily or on the given weekday.
+type RecordingSchedule struct {
+	Channel  string
+	Weekday  *time.Weekday
+	Start    string
+	Duration time.Duration
+}
+
+// RecordingOptions configure where and what is recorded.
+type RecordingOptions struct {
+	Dir string
+	// Rotate is how often continuous recordings start a new file.
+	Rotate time.Duration
+	// MaxAge and MaxSize limit how much is kept. Finished recordings are
+	// deleted oldest first. Zero means no limit.
+	MaxAge  time.Duration
+	MaxSize int64
+	// FeedMinDuration leaves shorter recordings out of podcast feeds.
+	FeedMinDuration time.Duration
+	// Continuous lists channels, by name, that are always recorded.
+	Continuous []string
+	Schedule   []RecordingSchedule
+}
+
+type recorderEvent struct {
+	data  []byte
+	track string
+	at    time.Time
+}
+
+// recorder writes a channel's broadcast to disk. Events arrive through a tap
+// on the broadcaster and are written by a single goroutine.
+type recorder struct {
+	b      *broadcaster
+	kind   RecordingKind
+	rotate time.Duration
+	until  time.Time
+	events chan recorderEvent
+	stop   chan struct{}
+	// stopOnce guards stop, which several callers may close at once.
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu      sync.Mutex
+	current Recording
+	file    *os.File
+	track   string
+	dropped int64
+}
+
+// SetRecordingOptions configures recordings. It must be called before
+// Broadcast.
+func (r *Radio) SetRecordingOptions(opts RecordingOptions) {
+	r.recordingOpts = opts
+}
+
+// StartRecording starts recording a channel. A zero until records until
+// StopRecording is called.
+func (r *Radio) StartRecording(channelID string, kind RecordingKind, until time.Time) (Recording, error) {
+	b, ok := r.getBroadcaster(channelID)
+	if !ok {
+		return Recording{}, ErrChannelNotFound
+	}
+
+	r.recordersMux.Lock()
+	defer r.recordersMux.Unlock()
+
+	key := recorderKey(channelID, kind)
+	if _, ok := r.recorders[key]; ok {
+		return Recording{}, ErrAlreadyRecording
+	}
+
+	rec := &recorder{
+		b:      b,
+		kind:   kind,
+		until:  until,
+		track:  b.currentTrack(),
+		events: make(chan recorderEvent, recorderQueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if kind == RecordingContinuous {
+		rec.rotate = r.recordingOpts.Rotate
+	}
+
+	if err := r.openRecordingFile(rec, time.Now()); err != nil {
+		return Recording{}, err
+	}
+
+	r.recorders[key] = rec
+	b.addTap(rec)
+
+	snapshot := rec.snapshot()
+	go r.runRecorder(rec)
+
+	r.logger(b.channel).Info("recording started", "recording", snapshot.ID, "kind", kind)
+
+	return snapshot, nil
+}
+
+// StopRecording stops a recording of the given kind on a channel.
+func (r *Radio) StopRecording(channelID string, kind RecordingKind) (Recording, error) {
+	if _, ok := r.getBroadcaster(channelID); !ok {
+		return Recording{}, ErrChannelNotFound
+	}
+
+	r.recordersMux.Lock()
+	rec, ok := r.recorders[recorderKey(channelID, kind)]
+	r.recordersMux.Unlock()
+	if !ok {
+		return Recording{}, ErrNotRecording
+	}
+
+	rec.close()
+	<-rec.done
+
+	return rec.snapshot(), nil
+}
+
+// Recordings lists recordings, newest first. An empty channelID lists every
+// channel.
+func (r *Radio) Recordings(channelID string) ([]Recording, error) {
+	recordings, err := r.scanRecordings()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Recording{}
+	for _, rec := range recordings {
+		if channelID != "" && rec.ChannelID != channelID {
+			continue
+		}
+		rec.Cues = nil
+		result = append(result, rec)
+	}
+	return result, nil
+}
+
+// Recording returns a recording with its cue list.
+func (r *Radio) Recording(id string) (Recording, error) {
+	recordings, err := r.scanRecordings()
+	if err != nil {
+		return Recording{}, err
+	}
+	for _, rec := range recordings {
+		if rec.ID == id {
+			return rec, nil
+		}
+	}
+	return Recording{}, ErrRecordingNotFound
+}
+
+// OpenRecording opens the audio file of a recording for reading.
+func (r *Radio) OpenRecording(id string) (*os.File, Recording, error) {
+	rec, err := r.Recording(id)
+	if err != nil {
+		return nil, Recording{}, err
+	}
+	file, err := os.Open(rec.path)
+	if err != nil {
+		return nil, Recording{}, err
+	}
+	return file, rec, nil
+}
+
+func recorderKey(channelID string, kind RecordingKind) string {
+	return channelID + "/" + string(kind)
+}
+
+func (r *Radio) recordingsDir() string {
+	if r.recordingOpts.Dir != "" {
+		return r.recordingOpts.Dir
+	}
+	return filepath.Join(r.dir, ".recordings")
+}
+
+// openRecordingFile starts a new file for rec. The previous file, if any, must
+// have been finished.
+func (r *Radio) openRecordingFile(rec *recorder, now time.Time) error {
+	dir := filepath.Join(r.recordingsDir(), rec.b.channel.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+
+	id := fmt.Sprintf("%s-%s-%s", rec.b.channel.Name, now.Format(recordingTimeFormat), rec.kind)
+	path := filepath.Join(dir, id+".mp3")
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.file = file
+	rec.current = Recording{
+		ID:        id,
+		ChannelID: rec.b.channel.ID,
+		Channel:   rec.b.channel.Name,
+		Kind:      rec.kind,
+		StartedAt: now,
+		Active:    true,
+		Cues:      []RecordingCue{},
+		path:      path,
+	}
+	if rec.track != "" {
+		// Recordings started mid-track, or rotated, begin with that track.
+		rec.current.Cues = append(rec.current.Cues, RecordingCue{Track: rec.track, At: now})
+	}
+
+	return writeSidecar(rec.current)
+}
+
+func (r *Radio) runRecorder(rec *recorder) {
+	defer close(rec.done)
+
+	logger := r.logger(rec.b.channel)
+
+	var deadline <-chan time.Time
+	if !rec.until.IsZero() {
+		timer := time.NewTimer(time.Until(rec.until))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	nextRotate := rotationTime(time.Now(), rec.rotate)
+
+	// handle writes an event, rotating the file first when it is due.
+	handle := func(ev recorderEvent) error {
+		if !nextRotate.IsZero() && !ev.at.Before(nextRotate) {
+			if err := r.rotateRecording(rec, ev.at); err != nil {
+				logger.Error("failed to rotate recording", "error", err)
+				rec.fail(err)
+				return err
+			}
+			nextRotate = rotationTime(ev.at, rec.rotate)
+		}
+		if err := rec.write(ev); err != nil {
+			logger.Error("failed to write recording", "recording", rec.current.ID, "error", err)
+			rec.fail(err)
+			return err
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case ev := <-rec.events:
+			if err := handle(ev); err != nil {
+				r.finishRecorder(rec)
+				return
+			}
+		case <-deadline:
+			r.drainRecorder(rec, handle)
+			return
+		case <-rec.stop:
+			r.drainRecorder(rec, handle)
+			return
+		}
+	}
+}
+
+// drainRecorder detaches rec from its channel so no more events arrive, writes
+// the events still queued and then finishes the recording, so stopping does
+// not cut off the audio the broadcaster already handed over.
+func (r *Radio) drainRecorder(rec *recorder, handle func(recorderEvent) error) {
+	rec.b.removeTap(rec)
+	for {
+		select {
+		case ev := <-rec.events:
+			if err := handle(ev); err != nil {
+				r.finishRecorder(rec)
+				return
+			}
+		default:
+			r.finishRecorder(rec)
+			return
+		}
+	}
+}
+
+// rotationTime returns the next multiple of rotate after now, or the zero
+// time if rotate is not set.
+func rotationTime(now time.Time, rotate time.Duration) time.Time {
+	if rotate <= 0 {
+		return time.Time{}
+	}
+	return now.Truncate(rotate).Add(rotate)
+}
+
+func (r *Radio) rotateRecording(rec *recorder, now time.Time) error {
+	if err := rec.finishFile(now); err != nil {
+		return err
+	}
+	if err := r.openRecordingFile(rec, now); err != nil {
+		return err
+	}
+	r.pruneRecordings()
+	return nil
+}
+
+// finishRecorder detaches rec from its channel and closes its file.
+func (r *Radio) finishRecorder(rec *recorder) {
+	rec.b.removeTap(rec)
+
+	r.recordersMux.Lock()
+	delete(r.recorders, recorderKey(rec.b.channel.ID, rec.kind))
+	r.recordersMux.Unlock()
+
+	logger := r.logger(rec.b.channel)
+	if err := rec.finishFile(time.Now()); err != nil {
+		logger.Error("failed to finish recording", "recording", rec.current.ID, "error", err)
+	}
+
+	rec.mu.Lock()
+	dropped := rec.dropped
+	rec.mu.Unlock()
+	logger.Info("recording stopped", "recording", rec.current.ID, "dropped_chunks", dropped)
+
+	r.pruneRecordings()
+}
+
+// fail marks the current recording as failed.
+func (rec *recorder) fail(err error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.current.Error = err.Error()
+}
+
+func (rec *recorder) close() {
+	rec.stopOnce.Do(func() {
+		close(rec.stop)
+	})
+}
+
+// send queues an event without blocking the broadcast loop.
+func (rec *recorder) send(ev recorderEvent) {
+	select {
+	case rec.events <- ev:
+	default:
+		rec.mu.Lock()
+		rec.dropped++
+		rec.mu.Unlock()
+	}
+}
+
+func (rec *recorder) write(ev recorderEvent) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if ev.track != "" {
+		rec.track = ev.track
+		rec.current.Cues = append(rec.current.Cues, RecordingCue{
+			Track:  ev.track,
+			Offset: ev.at.Sub(rec.current.StartedAt).Seconds(),
+			Byte:   rec.current.Size,
+			At:     ev.at,
+		})
+		return writeSidecar(rec.current)
+	}
+
+	n, err := rec.file.Write(ev.data)
+	rec.current.Size += int64(n)
+	return err
+}
+
+func (rec *recorder) finishFile(now time.Time) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.current.EndedAt = &now
+	rec.current.Active = false
+
+	return errors.Join(rec.file.Close(), writeSidecar(rec.current))
+}
+
+func (rec *recorder) snapshot() Recording {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	snapshot := rec.current
+	snapshot.Cues = slices.Clone(rec.current.Cues)
+	return snapshot
+}
+
+// writeSidecar replaces the JSON file describing a recording.
+func writeSidecar(rec Recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := strings.TrimSuffix(rec.path, ".mp3") + ".json"
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// scanRecordings reads the sidecars of every recording, newest first.
+func (r *Radio) scanRecordings() ([]Recording, error) {
+	recordings := []Recording{}
+
+	err := filepath.WalkDir(r.recordingsDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("invalid recording sidecar %s: %w", path, err)
+		}
+		rec.path = strings.TrimSuffix(path, ".json") + ".mp3"
+		if info, err := os.Stat(rec.path); err == nil {
+			rec.Size = info.Size()
+		} else {
+			return nil
+		}
+		recordings = append(recordings, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(recordings, func(a, b Recording) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return recordings, nil
+}
+
+// recoverRecordings ends recordings that are still marked active, which the
+// server was writing when it last stopped without finishing them. They end
+// when their file was last written to.
+func (r *Radio) recoverRecordings() {
+	recordings, err := r.scanRecordings()
+	if err != nil {
+		slog.Error("failed to list recordings", "error", err)
+		return
+	}
+
+	for _, rec := range recordings {
+		if !rec.Active {
+			continue
+		}
+		endedAt := rec.StartedAt
+		if info, err := os.Stat(rec.path); err == nil {
+			endedAt = info.ModTime()
+		}
+		rec.Active = false
+		rec.EndedAt = &endedAt
+		rec.Error = "interrupted by a server restart"
+		if err := writeSidecar(rec); err != nil {
+			slog.Error("failed to end interrupted recording", "recording", rec.ID, "error", err)
+			continue
+		}
+		slog.Warn("ended interrupted recording", "recording", rec.ID)
+	}
+}
+
+// pruneRecordings deletes finished recordings beyond the retention limits.
+func (r *Radio) pruneRecordings() {
+	opts := r.recordingOpts
+	if opts.MaxAge <= 0 && opts.MaxSize <= 0 {
+		return
+	}
+
+	r.pruneMux.Lock()
+	defer r.pruneMux.Unlock()
+
+	recordings, err := r.scanRecordings()
+	if err != nil {
+		slog.Error("failed to list recordings", "error", err)
+		return
+	}
+
+	var total int64
+	for _, rec := range recordings {
+		total += rec.Size
+	}
+
+	// Oldest first.
+	for i := len(recordings) - 1; i >= 0; i-- {
+		rec := recordings[i]
+		if rec.Active {
+			continue
+		}
+		expired := opts.MaxAge > 0 && time.Since(rec.StartedAt) > opts.MaxAge
+		oversize := opts.MaxSize > 0 && total > opts.MaxSize
+		if !expired && !oversize {
+			continue
+		}
+
+		err := errors.Join(
+			os.Remove(rec.path),
+			os.Remove(strings.TrimSuffix(rec.path, ".mp3")+".json"),
+		)
+		if err != nil {
+			slog.Error("failed to delete recording", "recording", rec.ID, "error", err)
+			continue
+		}
+		total -= rec.Size
+		slog.Info("deleted recording", "recording", rec.ID)
+	}
+}
+
+func (b *broadcaster) addTap(rec *recorder) {
+	b.mu.Lock()
+	if b.taps == nil {
+		b.taps = make(map[*recorder]struct{})
+	}
+	b.taps[rec] = struct{}{}
+	b.mu.Unlock()
+
+	// An idle channel has to play again to be recorded.
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *broadcaster) removeTap(rec *recorder) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.taps, rec)
+}
+
+func (b *broadcaster) isRecording() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.taps) > 0
+}
+
+// tap passes an event to every recorder of the channel.
+func (b *broadcaster) tap(ev recorderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for rec := range b.taps {
+		rec.send(ev)
+	}
+}
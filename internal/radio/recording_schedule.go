@@ -0,0 +1,114 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// recordingCheckInterval is how often the recording schedule is checked.
+const recordingCheckInterval = 30 * time.Second
+
+// runRecordings starts the continuous recordings and follows the schedule
+// until ctx is done, then stops every recording.
+func (r *Radio) runRecordings(ctx context.Context) {
+	r.recoverRecordings()
+
+	for _, name := range r.recordingOpts.Continuous {
+		channel, ok := r.channelByName(name)
+		if !ok {
+			r.logger(Channel{Name: name}).Warn("cannot record unknown channel")
+			continue
+		}
+		if _, err := r.StartRecording(channel.ID, RecordingContinuous, time.Time{}); err != nil {
+			r.logger(channel).Error("failed to start continuous recording", "error", err)
+		}
+	}
+
+	started := make([]time.Time, len(r.recordingOpts.Schedule))
+
+	ticker := time.NewTicker(recordingCheckInterval)
+	defer ticker.Stop()
+
+	r.pruneRecordings()
+	lastPrune := time.Now()
+
+	for {
+		now := time.Now()
+		for i, entry := range r.recordingOpts.Schedule {
+			start := entry.occurrence(now)
+			end := start.Add(entry.Duration)
+			if now.Before(end) && !started[i].Equal(start) {
+				started[i] = start
+				r.startScheduled(entry, end)
+			}
+		}
+
+		if now.Sub(lastPrune) >= time.Hour {
+			r.pruneRecordings()
+			lastPrune = now
+		}
+
+		select {
+		case <-ctx.Done():
+			r.stopRecordings()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Radio) startScheduled(entry RecordingSchedule, until time.Time) {
+	channel, ok := r.channelByName(entry.Channel)
+	if !ok {
+		r.logger(Channel{Name: entry.Channel}).Warn("cannot record unknown channel")
+		return
+	}
+	_, err := r.StartRecording(channel.ID, RecordingScheduled, until)
+	if err != nil && !errors.Is(err, ErrAlreadyRecording) {
+		r.logger(channel).Error("failed to start scheduled recording", "error", err)
+	}
+}
+
+func (r *Radio) stopRecordings() {
+	r.recordersMux.Lock()
+	recorders := make([]*recorder, 0, len(r.recorders))
+	for _, rec := range r.recorders {
+		recorders = append(recorders, rec)
+	}
+	r.recordersMux.Unlock()
+
+	for _, rec := range recorders {
+		rec.close()
+		<-rec.done
+	}
+}
+
+// occurrence returns the latest start of the scheduled recording at or
+// before now.
+func (s RecordingSchedule) occurrence(now time.Time) time.Time {
+	t, err := time.Parse("15:04", s.Start)
+	if err != nil {
+		return time.Time{}
+	}
+
+	start := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1)
+	}
+	if s.Weekday != nil {
+		for start.Weekday() != *s.Weekday {
+			start = start.AddDate(0, 0, -1)
+		}
+	}
+	return start
+}
+
+func (r *Radio) channelByName(name string) (Channel, bool) {
+	for _, channel := range r.channels {
+		if channel.Name == name {
+			return channel, true
+		}
+	}
+	return Channel{}, false
+}
@@ -0,0 +1,251 @@
+package radio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func activeRecorder(r *Radio, channelID string, kind RecordingKind) *recorder {
+	r.recordersMux.Lock()
+	defer r.recordersMux.Unlock()
+	return r.recorders[recorderKey(channelID, kind)]
+}
+
+// waitForSize waits until the recorder has written size bytes.
+func waitForSize(t *testing.T, rec *recorder, size int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for rec.snapshot().Size < size {
+		if time.Now().After(deadline) {
+			t.Fatalf("recorded %d bytes, want %d", rec.snapshot().Size, size)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRecording(t *testing.T) {
+	r := newTestRadio(t, "a")
+	b := r.broadcasterMap["a"]
+	b.trackStarted("1.mp3", time.Now())
+
+	started, err := r.StartRecording("a", RecordingManual, time.Time{})
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+	if _, err := r.StartRecording("a", RecordingManual, time.Time{}); !errors.Is(err, ErrAlreadyRecording) {
+		t.Errorf("second StartRecording() error = %v, want %v", err, ErrAlreadyRecording)
+	}
+
+	b.tap(recorderEvent{data: []byte("abc"), at: time.Now()})
+	b.trackStarted("2.mp3", time.Now())
+	b.tap(recorderEvent{data: []byte("defg"), at: time.Now()})
+	waitForSize(t, activeRecorder(r, "a", RecordingManual), 7)
+
+	stopped, err := r.StopRecording("a", RecordingManual)
+	if err != nil {
+		t.Fatalf("StopRecording() error = %v", err)
+	}
+	if _, err := r.StopRecording("a", RecordingManual); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("second StopRecording() error = %v, want %v", err, ErrNotRecording)
+	}
+	if b.isRecording() {
+		t.Error("channel still tapped after StopRecording()")
+	}
+
+	got, err := r.Recording(started.ID)
+	if err != nil {
+		t.Fatalf("Recording() error = %v", err)
+	}
+	if got.Active || got.EndedAt == nil || !got.EndedAt.Equal(*stopped.EndedAt) {
+		t.Errorf("recording active = %v, ended at %v, want it finished at %v", got.Active, got.EndedAt, stopped.EndedAt)
+	}
+	if got.Size != 7 {
+		t.Errorf("Size = %d, want 7", got.Size)
+	}
+	var cues []string
+	for _, cue := range got.Cues {
+		cues = append(cues, cue.Track)
+	}
+	if want := []string{"1.mp3", "2.mp3"}; !slices.Equal(cues, want) {
+		t.Errorf("cues = %v, want %v", cues, want)
+	}
+	if got.Cues[1].Byte != 3 {
+		t.Errorf("second cue at byte %d, want 3", got.Cues[1].Byte)
+	}
+
+	file, _, err := r.OpenRecording(started.ID)
+	if err != nil {
+		t.Fatalf("OpenRecording() error = %v", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte("abcdefg")) {
+		t.Errorf("recorded %q, want %q", data, "abcdefg")
+	}
+}
+
+func TestStopRecordingDrainsEvents(t *testing.T) {
+	r := newTestRadio(t, "a")
+	b := r.broadcasterMap["a"]
+
+	started, err := r.StartRecording("a", RecordingManual, time.Time{})
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+	// Stop right away, while most of the chunks are still queued.
+	for range 100 {
+		b.tap(recorderEvent{data: []byte("chunk"), at: time.Now()})
+	}
+	if _, err := r.StopRecording("a", RecordingManual); err != nil {
+		t.Fatalf("StopRecording() error = %v", err)
+	}
+
+	got, err := r.Recording(started.ID)
+	if err != nil {
+		t.Fatalf("Recording() error = %v", err)
+	}
+	if want := int64(100 * len("chunk")); got.Size != want {
+		t.Errorf("Size = %d, want %d", got.Size, want)
+	}
+}
+
+func TestRecordingUntil(t *testing.T) {
+	r := newTestRadio(t, "a")
+
+	started, err := r.StartRecording("a", RecordingScheduled, time.Now().Add(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+	<-activeRecorder(r, "a", RecordingScheduled).done
+
+	got, err := r.Recording(started.ID)
+	if err != nil {
+		t.Fatalf("Recording() error = %v", err)
+	}
+	if got.Active || got.EndedAt == nil {
+		t.Errorf("recording active = %v, ended at %v, want it finished", got.Active, got.EndedAt)
+	}
+	if _, err := r.StopRecording("a", RecordingScheduled); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("StopRecording() after the deadline error = %v, want %v", err, ErrNotRecording)
+	}
+}
+
+func TestRecordings(t *testing.T) {
+	r := newTestRadio(t, "a", "b")
+	var ids []string
+	for _, channelID := range []string{"a", "b"} {
+		rec, err := r.StartRecording(channelID, RecordingManual, time.Time{})
+		if err != nil {
+			t.Fatalf("StartRecording() error = %v", err)
+		}
+		if _, err := r.StopRecording(channelID, RecordingManual); err != nil {
+			t.Fatalf("StopRecording() error = %v", err)
+		}
+		ids = append(ids, rec.ID)
+	}
+
+	tests := []struct {
+		channelID string
+		want      []string
+	}{
+		{"", []string{ids[1], ids[0]}},
+		{"a", []string{ids[0]}},
+		{"c", nil},
+	}
+	for _, tt := range tests {
+		recordings, err := r.Recordings(tt.channelID)
+		if err != nil {
+			t.Fatalf("Recordings(%q) error = %v", tt.channelID, err)
+		}
+		var got []string
+		for _, rec := range recordings {
+			got = append(got, rec.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Recordings(%q) = %v, want %v", tt.channelID, got, tt.want)
+		}
+	}
+
+	if _, err := r.Recording("missing"); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("Recording() error = %v, want %v", err, ErrRecordingNotFound)
+	}
+}
+
+func TestRotationTime(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	tests := []struct {
+		rotate time.Duration
+		want   time.Time
+	}{
+		{0, time.Time{}},
+		{time.Hour, time.Date(2026, 3, 14, 16, 0, 0, 0, time.UTC)},
+		{15 * time.Minute, time.Date(2026, 3, 14, 15, 15, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := rotationTime(now, tt.rotate); !got.Equal(tt.want) {
+			t.Errorf("rotationTime(%v) = %v, want %v", tt.rotate, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleOccurrence(t *testing.T) {
+	// A Saturday afternoon.
+	now := time.Date(2026, 3, 14, 15, 9, 0, 0, time.UTC)
+	monday := time.Monday
+	saturday := time.Saturday
+
+	tests := []struct {
+		name     string
+		schedule RecordingSchedule
+		want     time.Time
+	}{
+		{name: "earlier today", schedule: RecordingSchedule{Start: "09:00"}, want: time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)},
+		{name: "now", schedule: RecordingSchedule{Start: "15:09"}, want: now},
+		{name: "later today", schedule: RecordingSchedule{Start: "18:00"}, want: time.Date(2026, 3, 13, 18, 0, 0, 0, time.UTC)},
+		{name: "weekday", schedule: RecordingSchedule{Start: "18:00", Weekday: &monday}, want: time.Date(2026, 3, 9, 18, 0, 0, 0, time.UTC)},
+		{name: "today's weekday", schedule: RecordingSchedule{Start: "09:00", Weekday: &saturday}, want: time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)},
+		{name: "invalid start", schedule: RecordingSchedule{Start: "soon"}, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.occurrence(now); !got.Equal(tt.want) {
+				t.Errorf("occurrence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoverRecordings(t *testing.T) {
+	r := newTestRadio(t, "a")
+	if _, err := r.StartRecording("a", RecordingManual, time.Time{}); err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+	// Simulate a crash: the recorder goes away without finishing its file.
+	rec := activeRecorder(r, "a", RecordingManual)
+	rec.b.removeTap(rec)
+	rec.file.Close()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(rec.current.path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	r.recoverRecordings()
+
+	got, err := r.Recording(rec.current.ID)
+	if err != nil {
+		t.Fatalf("Recording() error = %v", err)
+	}
+	if got.Active || got.EndedAt == nil || !got.EndedAt.Equal(modTime) || got.Error == "" {
+		t.Errorf("recovered recording active = %v, ended at %v, error %q, want it ended at %v with an error",
+			got.Active, got.EndedAt, got.Error, modTime)
+	}
+}
@@ -0,0 +1,146 @@
+package radio
+
+import "time"
+
+// Settings tune a channel's broadcast loop.
+type Settings struct {
+	BufferLength int
+	ChunkSize    int
+	TickInterval time.Duration
+	// Mode decides how the channel moves through its tracks.
+	Mode PlaybackMode
+	// Epoch is when the rotation starts in ModeTimeline.
+	Epoch time.Time
+	// IdleAfter suspends the channel once it has had no listeners for this
+	// long. It resumes at the position it would have reached when a listener
+	// joins. Zero keeps the channel playing.
+	IdleAfter time.Duration
+	// WriteTimeout bounds each write to a listener. Listeners that cannot
+	// keep up are disconnected as slow consumers.
+	WriteTimeout time.Duration
+	// QueueSize is the number of chunks buffered per listener.
+	QueueSize int
+	// QueuePolicy decides what happens when a listener's queue is full.
+	QueuePolicy QueuePolicy
+	// MaxLag is how far a listener may fall behind under QueueDisconnect.
+	MaxLag time.Duration
+	// TimeShift is how much of the broadcast is kept on disk for listeners
+	// who start behind live. Zero disables time shifting.
+	TimeShift time.Duration
+	// MaxListeners overrides Limits.MaxChannelListeners when positive. A
+	// negative value lifts the limit for the channel.
+	MaxListeners int
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		BufferLength: 28,
+		ChunkSize:    1024 * 4,
+		TickInterval: 170 * time.Millisecond,
+		Mode:         ModeSequential,
+		Epoch:        time.Unix(0, 0).UTC(),
+		WriteTimeout: 10 * time.Second,
+		QueueSize:    8,
+		QueuePolicy:  QueueDropOldest,
+		MaxLag:       10 * time.Second,
+	}
+}
+
+// merge returns s with its zero fields taken from defaults.
+func (s Settings) merge(defaults Settings) Settings {
+	if s.BufferLength <= 0 {
+		s.BufferLength = defaults.BufferLength
+	}
+	if s.ChunkSize <= 0 {
+		s.ChunkSize = defaults.ChunkSize
+	}
+	if s.TickInterval <= 0 {
+		s.TickInterval = defaults.TickInterval
+	}
+	if !s.Mode.Valid() {
+		s.Mode = defaults.Mode
+	}
+	if s.Epoch.IsZero() {
+		s.Epoch = defaults.Epoch
+	}
+	if s.IdleAfter <= 0 {
+		s.IdleAfter = defaults.IdleAfter
+	}
+	if s.WriteTimeout <= 0 {
+		s.WriteTimeout = defaults.WriteTimeout
+	}
+	if s.QueueSize <= 0 {
+		s.QueueSize = defaults.QueueSize
+	}
+	if !s.QueuePolicy.Valid() {
+		s.QueuePolicy = defaults.QueuePolicy
+	}
+	if s.MaxLag <= 0 {
+		s.MaxLag = defaults.MaxLag
+	}
+	if s.TimeShift <= 0 {
+		s.TimeShift = defaults.TimeShift
+	}
+	if s.MaxListeners == 0 {
+		s.MaxListeners = defaults.MaxListeners
+	}
+	return s
+}
+
+// ChannelSettings override the default settings for one channel. Zero fields
+// inherit the default. The settings where zero turns something off are
+// pointers, so a channel can set them to zero explicitly.
+type ChannelSettings struct {
+	BufferLength int
+	ChunkSize    int
+	TickInterval time.Duration
+	Mode         PlaybackMode
+	Epoch        time.Time
+	IdleAfter    *time.Duration
+	WriteTimeout time.Duration
+	QueueSize    int
+	QueuePolicy  QueuePolicy
+	MaxLag       time.Duration
+	TimeShift    *time.Duration
+	MaxListeners *int
+}
+
+// apply returns defaults with the overrides of c.
+func (c ChannelSettings) apply(defaults Settings) Settings {
+	s := Settings{
+		BufferLength: c.BufferLength,
+		ChunkSize:    c.ChunkSize,
+		TickInterval: c.TickInterval,
+		Mode:         c.Mode,
+		Epoch:        c.Epoch,
+		WriteTimeout: c.WriteTimeout,
+		QueueSize:    c.QueueSize,
+		QueuePolicy:  c.QueuePolicy,
+		MaxLag:       c.MaxLag,
+	}.merge(defaults)
+	if c.IdleAfter != nil {
+		s.IdleAfter = *c.IdleAfter
+	}
+	if c.TimeShift != nil {
+		s.TimeShift = *c.TimeShift
+	}
+	if c.MaxListeners != nil {
+		s.MaxListeners = *c.MaxListeners
+		// An explicit 0 lifts the limit, which Settings spells negative.
+		if s.MaxListeners == 0 {
+			s.MaxListeners = -1
+		}
+	}
+	return s
+}
+
+// SetSettings sets the default broadcast settings and per-channel overrides
+// keyed by channel name. It must be called before LoadChannels.
+func (r *Radio) SetSettings(defaults Settings, channels map[string]ChannelSettings) {
+	r.settings = defaults.merge(DefaultSettings())
+	r.channelSettings = channels
+}
+
+func (r *Radio) settingsFor(channel Channel) Settings {
+	return r.channelSettings[channel.Name].apply(r.settings)
+}
@@ -0,0 +1,206 @@
+package radio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/mp3"
+)
+
+// trackListName is the per-channel file of track overrides, keyed by file
+// name. A track's own sidecar, named after it with .json appended, takes
+// precedence over its entry there.
+const trackListName = "tracks.json"
+
+// trackOverride replaces what is read from a track's tags and changes how it
+// is played. Unset fields leave the track as it is.
+type trackOverride struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	// Artwork is the path of an image, relative to the track's directory.
+	Artwork string `json:"artwork"`
+	// Gain is the volume change in dB, applied in steps of mp3.GainStep.
+	Gain *float64 `json:"gain"`
+	// CueIn and CueOut are the seconds into the track where playback starts
+	// and stops, to trim intros and outros.
+	CueIn  *float64 `json:"cue_in"`
+	CueOut *float64 `json:"cue_out"`
+	// Weight is how many times the track plays in each rotation of its
+	// channel. Zero leaves it out.
+	Weight *int `json:"weight"`
+}
+
+// merge returns o with the fields set in other replacing its own.
+func (o trackOverride) merge(other trackOverride) trackOverride {
+	if other.Title != "" {
+		o.Title = other.Title
+	}
+	if other.Artist != "" {
+		o.Artist = other.Artist
+	}
+	if other.Album != "" {
+		o.Album = other.Album
+	}
+	if other.Artwork != "" {
+		o.Artwork = other.Artwork
+	}
+	if other.Gain != nil {
+		o.Gain = other.Gain
+	}
+	if other.CueIn != nil {
+		o.CueIn = other.CueIn
+	}
+	if other.CueOut != nil {
+		o.CueOut = other.CueOut
+	}
+	if other.Weight != nil {
+		o.Weight = other.Weight
+	}
+	return o
+}
+
+func (o trackOverride) validate() error {
+	switch {
+	case o.CueIn != nil && *o.CueIn < 0:
+		return errors.New("cue_in must not be negative")
+	case o.CueOut != nil && *o.CueOut <= 0:
+		return errors.New("cue_out must be positive")
+	case o.CueIn != nil && o.CueOut != nil && *o.CueOut <= *o.CueIn:
+		return errors.New("cue_out must be after cue_in")
+	case o.Weight != nil && *o.Weight < 0:
+		return errors.New("weight must not be negative")
+	}
+	return nil
+}
+
+// cues returns where playback of a track of the given duration starts and
+// stops.
+func (o trackOverride) cues(duration time.Duration) (in, out time.Duration) {
+	out = duration
+	if o.CueIn != nil {
+		in = min(seconds(*o.CueIn), duration)
+	}
+	if o.CueOut != nil {
+		out = max(min(seconds(*o.CueOut), duration), in)
+	}
+	return in, out
+}
+
+// gainSteps returns the track's gain in global gain steps.
+func (o trackOverride) gainSteps() int {
+	if o.Gain == nil {
+		return 0
+	}
+	return mp3.GainSteps(*o.Gain)
+}
+
+func (o trackOverride) weight() int {
+	if o.Weight == nil {
+		return 1
+	}
+	return *o.Weight
+}
+
+// applyTrackOverride sets the cue points and gain of a sequential plan from
+// the track's overrides. Invalid overrides are logged and ignored.
+func (r *Radio) applyTrackOverride(b *broadcaster, plan *trackPlan) {
+	o, err := r.trackOverride(plan.path)
+	if err != nil {
+		r.logger(b.channel).Warn("ignoring track overrides", "track", filepath.Base(plan.path), "error", err)
+		return
+	}
+	if o.CueIn != nil {
+		plan.cueIn = seconds(*o.CueIn)
+	}
+	if o.CueOut != nil {
+		plan.cueOut = seconds(*o.CueOut)
+	}
+	plan.gain = o.gainSteps()
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// trackOverride reads the overrides of the track at path from the channel's
+// track list and the track's sidecar. Missing files are not an error.
+func (r *Radio) trackOverride(path string) (trackOverride, error) {
+	var o trackOverride
+
+	list, err := r.trackLists.get(filepath.Join(filepath.Dir(path), trackListName), func(file *os.File) (map[string]trackOverride, error) {
+		var list map[string]trackOverride
+		return list, decodeOverride(file, &list)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return o, fmt.Errorf("invalid %s: %w", trackListName, err)
+	}
+	o = o.merge(list[filepath.Base(path)])
+
+	sidecar, err := r.sidecars.get(path+".json", func(file *os.File) (trackOverride, error) {
+		var o trackOverride
+		return o, decodeOverride(file, &o)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return o, fmt.Errorf("invalid %s: %w", filepath.Base(path)+".json", err)
+	}
+	o = o.merge(sidecar)
+
+	if err := o.validate(); err != nil {
+		return trackOverride{}, fmt.Errorf("invalid overrides for %s: %w", filepath.Base(path), err)
+	}
+	return o, nil
+}
+
+func decodeOverride(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// overrideArtwork caches the artwork named by an override and returns its
+// hash.
+func (r *Radio) overrideArtwork(trackPath string, o trackOverride) (string, error) {
+	path := o.Artwork
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(trackPath), path)
+	}
+	return r.coverHashes.get(path, func(file *os.File) (string, error) {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return "", err
+		}
+		img, err := r.artwork.Prepare(data)
+		if err != nil {
+			return "", err
+		}
+		return img.Hash, nil
+	})
+}
+
+// applyOverride replaces the parts of meta that o sets.
+func (r *Radio) applyOverride(meta *trackMeta, path string, o trackOverride) error {
+	if o.Title != "" {
+		meta.Title = o.Title
+	}
+	if o.Artist != "" {
+		meta.Artist = o.Artist
+	}
+	if o.Album != "" {
+		meta.Album = o.Album
+	}
+	if o.Artwork != "" {
+		hash, err := r.overrideArtwork(path, o)
+		if err != nil {
+			return fmt.Errorf("failed to read artwork %s: %w", o.Artwork, err)
+		}
+		meta.artwork = hash
+	}
+	return nil
+}
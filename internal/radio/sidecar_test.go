@@ -0,0 +1,120 @@
+package radio
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrackOverride(t *testing.T) {
+	tests := []struct {
+		name      string
+		trackList string
+		sidecar   string
+		want      trackOverride
+		err       string
+	}{
+		{name: "no overrides"},
+		{
+			name:      "track list",
+			trackList: `{"song.mp3": {"title": "Song", "cue_in": 2}, "other.mp3": {"title": "Other"}}`,
+			want:      trackOverride{Title: "Song", CueIn: ptr(2.0)},
+		},
+		{
+			name:      "sidecar wins",
+			trackList: `{"song.mp3": {"title": "Song", "artist": "Band", "gain": -3}}`,
+			sidecar:   `{"title": "Live", "weight": 2}`,
+			want:      trackOverride{Title: "Live", Artist: "Band", Gain: ptr(-3.0), Weight: ptr(2)},
+		},
+		{
+			name:    "unknown field",
+			sidecar: `{"titel": "Song"}`,
+			err:     "invalid song.mp3.json",
+		},
+		{
+			name:      "invalid track list",
+			trackList: `[]`,
+			err:       "invalid tracks.json",
+		},
+		{
+			name:      "cue out before cue in",
+			trackList: `{"song.mp3": {"cue_in": 30}}`,
+			sidecar:   `{"cue_out": 10}`,
+			err:       "cue_out must be after cue_in",
+		},
+		{
+			name:    "negative weight",
+			sidecar: `{"weight": -1}`,
+			err:     "weight must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(t.TempDir())
+			path := filepath.Join(r.dir, "song.mp3")
+			if tt.trackList != "" {
+				writeFile(t, filepath.Join(r.dir, trackListName), tt.trackList)
+			}
+			if tt.sidecar != "" {
+				writeFile(t, path+".json", tt.sidecar)
+			}
+
+			o, err := r.trackOverride(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("trackOverride() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("trackOverride() error = %v", err)
+			}
+			// Compare the JSON form to follow the pointer fields.
+			got, _ := json.Marshal(o)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("trackOverride() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestTrackOverrideCues(t *testing.T) {
+	duration := 3 * time.Minute
+	tests := []struct {
+		name    string
+		o       trackOverride
+		in, out time.Duration
+	}{
+		{name: "none", out: duration},
+		{name: "cue in", o: trackOverride{CueIn: ptr(10.5)}, in: 10500 * time.Millisecond, out: duration},
+		{name: "cue out", o: trackOverride{CueOut: ptr(120.0)}, out: 2 * time.Minute},
+		{name: "both", o: trackOverride{CueIn: ptr(10.0), CueOut: ptr(120.0)}, in: 10 * time.Second, out: 2 * time.Minute},
+		{name: "cue out past the end", o: trackOverride{CueOut: ptr(600.0)}, out: duration},
+		{name: "cue in past the end", o: trackOverride{CueIn: ptr(600.0)}, in: duration, out: duration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out := tt.o.cues(duration)
+			if in != tt.in || out != tt.out {
+				t.Errorf("cues() = %v, %v, want %v, %v", in, out, tt.in, tt.out)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
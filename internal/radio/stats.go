@@ -0,0 +1,216 @@
+package radio
+
+import (
+	"maps"
+	"net"
+	"time"
+)
+
+type Stats struct {
+	Listeners             int            `json:"listeners"`
+	PeakToday             int            `json:"peakToday"`
+	TotalSessions         int64          `json:"totalSessions"`
+	AverageSessionSeconds float64        `json:"averageSessionSeconds"`
+	BytesSent             int64          `json:"bytesSent"`
+	ChunksDropped         int64          `json:"chunksDropped"`
+	Channels              []ChannelStats `json:"channels"`
+}
+
+type ChannelStats struct {
+	ChannelID             string                     `json:"channelId"`
+	Listeners             int                        `json:"listeners"`
+	PeakToday             int                        `json:"peakToday"`
+	TotalSessions         int64                      `json:"totalSessions"`
+	AverageSessionSeconds float64                    `json:"averageSessionSeconds"`
+	BytesSent             int64                      `json:"bytesSent"`
+	ChunksDropped         int64                      `json:"chunksDropped"`
+	Disconnects           map[DisconnectReason]int64 `json:"disconnects"`
+	ListenerList          []ListenerStats            `json:"listenerList"`
+}
+
+type ListenerStats struct {
+	ID             string    `json:"id,omitempty"`
+	Addr           string    `json:"addr"`
+	UserAgent      string    `json:"userAgent"`
+	ConnectedSince time.Time `json:"connectedSince"`
+	BytesSent      int64     `json:"bytesSent"`
+	ChunksDropped  int64     `json:"chunksDropped"`
+	LagSeconds     float64   `json:"lagSeconds"`
+}
+
+// channelCounters holds the counters of a channel. Bytes and dropped chunks of
+// active listeners live on the listener and are folded in when it leaves.
+type channelCounters struct {
+	peak          int
+	peakDay       string
+	sessions      int64
+	ended         int64
+	sessionTime   time.Duration
+	bytesSent     int64
+	chunksDropped int64
+	disconnects   map[DisconnectReason]int64
+}
+
+type statsRecorder struct {
+	channels map[string]*channelCounters
+	peak     int
+	peakDay  string
+}
+
+func newStatsRecorder() statsRecorder {
+	return statsRecorder{channels: make(map[string]*channelCounters)}
+}
+
+func (s *statsRecorder) channel(channelID string) *channelCounters {
+	c, ok := s.channels[channelID]
+	if !ok {
+		c = &channelCounters{disconnects: make(map[DisconnectReason]int64)}
+		s.channels[channelID] = c
+	}
+	return c
+}
+
+func (s *statsRecorder) join(channelID string, channelListeners, totalListeners int, now time.Time) {
+	day := now.Format(time.DateOnly)
+
+	c := s.channel(channelID)
+	c.sessions++
+	if c.peakDay != day || channelListeners > c.peak {
+		c.peak = channelListeners
+		c.peakDay = day
+	}
+
+	if s.peakDay != day || totalListeners > s.peak {
+		s.peak = totalListeners
+		s.peakDay = day
+	}
+}
+
+func (s *statsRecorder) leave(session Session) {
+	c := s.channel(session.ChannelID)
+	c.ended++
+	c.sessionTime += session.EndedAt.Sub(session.ConnectedAt)
+	c.bytesSent += session.BytesSent
+	c.chunksDropped += session.ChunksDropped
+	c.disconnects[session.Reason]++
+}
+
+func peakToday(peak int, peakDay string, current int) int {
+	if peakDay != time.Now().Format(time.DateOnly) {
+		return current
+	}
+	return max(peak, current)
+}
+
+// Stats returns listener statistics for the whole server and every channel.
+func (r *Radio) Stats() Stats {
+	r.listenerMux.Lock()
+	defer r.listenerMux.Unlock()
+
+	stats := Stats{
+		Listeners: r.listenerCount,
+		PeakToday: peakToday(r.stats.peak, r.stats.peakDay, r.listenerCount),
+		Channels:  []ChannelStats{},
+	}
+
+	var ended int64
+	var sessionTime time.Duration
+	for _, channel := range r.channels {
+		cs := r.channelStats(channel.ID)
+		stats.TotalSessions += cs.TotalSessions
+		stats.BytesSent += cs.BytesSent
+		stats.ChunksDropped += cs.ChunksDropped
+		stats.Channels = append(stats.Channels, cs)
+
+		c := r.stats.channel(channel.ID)
+		ended += c.ended
+		sessionTime += c.sessionTime
+	}
+	stats.AverageSessionSeconds = averageSeconds(sessionTime, ended)
+
+	return stats
+}
+
+// ChannelStats returns listener statistics for a single channel.
+func (r *Radio) ChannelStats(channelID string) (ChannelStats, error) {
+	if _, ok := r.GetChannel(channelID); !ok {
+		return ChannelStats{}, ErrChannelNotFound
+	}
+
+	r.listenerMux.Lock()
+	defer r.listenerMux.Unlock()
+
+	return r.channelStats(channelID), nil
+}
+
+// channelStats must be called with listenerMux held.
+func (r *Radio) channelStats(channelID string) ChannelStats {
+	c := r.stats.channel(channelID)
+	listeners := r.listenerMap[channelID]
+	now := time.Now()
+
+	stats := ChannelStats{
+		ChannelID:             channelID,
+		Listeners:             len(listeners),
+		PeakToday:             peakToday(c.peak, c.peakDay, len(listeners)),
+		TotalSessions:         c.sessions,
+		AverageSessionSeconds: averageSeconds(c.sessionTime, c.ended),
+		BytesSent:             c.bytesSent,
+		ChunksDropped:         c.chunksDropped,
+		Disconnects:           maps.Clone(c.disconnects),
+		ListenerList:          make([]ListenerStats, 0, len(listeners)),
+	}
+
+	for _, l := range listeners {
+		ls := ListenerStats{
+			ID:             l.ID,
+			Addr:           anonymizeAddr(l.Addr),
+			UserAgent:      l.UserAgent,
+			ConnectedSince: l.ConnectedAt,
+			BytesSent:      l.bytesSent.Load(),
+			ChunksDropped:  l.chunksDropped.Load(),
+			LagSeconds:     l.queue.lag(now).Seconds(),
+		}
+		stats.BytesSent += ls.BytesSent
+		stats.ChunksDropped += ls.ChunksDropped
+		stats.ListenerList = append(stats.ListenerList, ls)
+	}
+
+	return stats
+}
+
+// RedactListenerIDs removes the IDs of the listeners, which can be used to
+// disconnect them.
+func (s *Stats) RedactListenerIDs() {
+	for i := range s.Channels {
+		s.Channels[i].RedactListenerIDs()
+	}
+}
+
+// RedactListenerIDs removes the IDs of the listeners, which can be used to
+// disconnect them.
+func (s *ChannelStats) RedactListenerIDs() {
+	for i := range s.ListenerList {
+		s.ListenerList[i].ID = ""
+	}
+}
+
+func averageSeconds(total time.Duration, count int64) float64 {
+	if count == 0 {
+		return 0
+	}
+	return total.Seconds() / float64(count)
+}
+
+// anonymizeAddr masks the host part of an IP address, keeping the /24 of IPv4
+// and the /48 of IPv6 addresses.
+func anonymizeAddr(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
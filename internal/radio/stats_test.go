@@ -0,0 +1,73 @@
+package radio
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStatsRedactListenerIDs(t *testing.T) {
+	r := newTestRadio(t, "a", "b")
+	for _, addr := range []string{"192.0.2.10", "2001:db8:1:2::1"} {
+		if _, err := r.Join(context.Background(), "a", addr, "test", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := r.Stats()
+	if len(stats.Channels) != 2 || len(stats.Channels[0].ListenerList) != 2 {
+		t.Fatalf("Stats() = %+v, want two listeners on the first of two channels", stats)
+	}
+	for _, l := range stats.Channels[0].ListenerList {
+		if l.ID == "" {
+			t.Errorf("listener %s has no ID before redacting", l.Addr)
+		}
+	}
+
+	stats.RedactListenerIDs()
+	var addrs []string
+	for _, l := range stats.Channels[0].ListenerList {
+		if l.ID != "" {
+			t.Errorf("listener %s still has ID %s", l.Addr, l.ID)
+		}
+		addrs = append(addrs, l.Addr)
+	}
+	if got := strings.Join(addrs, " "); got != "192.0.2.0 2001:db8:1::" && got != "2001:db8:1:: 192.0.2.0" {
+		t.Errorf("listener addresses = %s, want them anonymized", got)
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"id"`) {
+		t.Errorf("redacted stats JSON contains listener IDs: %s", data)
+	}
+
+	cs, err := r.ChannelStats("a")
+	if err != nil {
+		t.Fatalf("ChannelStats() error = %v", err)
+	}
+	cs.RedactListenerIDs()
+	if len(cs.ListenerList) != 2 || cs.ListenerList[0].ID != "" || cs.ListenerList[0].UserAgent != "test" {
+		t.Errorf("redacted channel listeners = %+v, want two without IDs", cs.ListenerList)
+	}
+}
+
+func TestAnonymizeAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"192.0.2.10", "192.0.2.0"},
+		{"::ffff:192.0.2.10", "192.0.2.0"},
+		{"2001:db8:1:2::1", "2001:db8:1::"},
+		{"not an address", ""},
+	}
+	for _, tt := range tests {
+		if got := anonymizeAddr(tt.addr); got != tt.want {
+			t.Errorf("anonymizeAddr(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = time.Minute
+	// A run lasting at least this long resets the restart backoff.
+	backoffResetAfter = 5 * time.Minute
+)
+
+// BroadcastChannel supervises the channel's broadcast loop. Failures stay
+// isolated to the channel: the loop is restarted with exponential backoff and
+// the channel is reported as degraded or offline in the meantime.
+func (r *Radio) BroadcastChannel(ctx context.Context, channel Channel) {
+	b, ok := r.getBroadcaster(channel.ID)
+	if !ok {
+		return
+	}
+
+	logger := r.logger(channel)
+	backoff := minRestartBackoff
+
+	for {
+		started := time.Now()
+		err := r.runChannel(ctx, b)
+		if ctx.Err() != nil {
+			b.setStatus(ChannelStatusStopped, nil)
+			logger.Info("broadcast stopped")
+			return
+		}
+
+		if time.Since(started) >= backoffResetAfter {
+			backoff = minRestartBackoff
+		}
+
+		wait := backoff
+		if errors.Is(err, errNoTracks) {
+			// Check for new files at a slow pace without escalating the backoff.
+			wait = maxRestartBackoff
+			b.setStatus(ChannelStatusOffline, err)
+			logger.Warn("channel offline", "error", err, "retry_in", wait)
+		} else {
+			backoff = min(backoff*2, maxRestartBackoff)
+			b.setStatus(ChannelStatusDegraded, err)
+			b.restarts.Add(1)
+			logger.Error("broadcast loop failed", "error", err, "retry_in", wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			b.setStatus(ChannelStatusStopped, nil)
+			logger.Info("broadcast stopped")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package radio
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/mp3"
+)
+
+// PlaybackMode decides how a channel moves through its tracks.
+type PlaybackMode string
+
+const (
+	// ModeSequential plays tracks one after another at a fixed chunk rate
+	// and resumes from the saved position after a restart.
+	ModeSequential PlaybackMode = "sequential"
+	// ModeTimeline derives the position from the wall clock, the channel
+	// epoch and the track durations, so every instance plays the same audio
+	// at the same time.
+	ModeTimeline PlaybackMode = "timeline"
+)
+
+func (m PlaybackMode) Valid() bool {
+	return m == ModeSequential || m == ModeTimeline
+}
+
+type timelineTrack struct {
+	name string
+	info mp3.Info
+	// The track plays from cueIn for length, after its overrides.
+	cueIn  time.Duration
+	length time.Duration
+	gain   int
+}
+
+// timeline maps wall-clock time to a position in a channel's rotation, which
+// repeats forever starting at epoch.
+type timeline struct {
+	epoch  time.Time
+	tracks []timelineTrack
+	total  time.Duration
+}
+
+// loadTimeline measures the channel's tracks, trimmed to their cue points.
+// Tracks whose duration cannot be determined are left out of the rotation.
+func (r *Radio) loadTimeline(b *broadcaster, sources []AudioSource) (*timeline, error) {
+	tl := &timeline{epoch: b.settings.Epoch}
+	logger := r.logger(b.channel)
+
+	for _, source := range sources {
+		path := filepath.Join(r.channelDir(b.channel), source.Name)
+		info, err := r.trackInfo(path)
+		if err != nil {
+			logger.Warn("leaving track out of timeline", "track", source.Name, "error", err)
+			continue
+		}
+		o, err := r.trackOverride(path)
+		if err != nil {
+			logger.Warn("ignoring track overrides", "track", source.Name, "error", err)
+		}
+		in, out := o.cues(info.Duration)
+		if out <= in {
+			logger.Warn("leaving track out of timeline", "track", source.Name, "error", "cue_in is past the end")
+			continue
+		}
+		tl.tracks = append(tl.tracks, timelineTrack{name: source.Name, info: info, cueIn: in, length: out - in, gain: o.gainSteps()})
+		tl.total += out - in
+	}
+
+	if tl.total <= 0 {
+		return nil, fmt.Errorf("no tracks with a known duration: %w", errNoTracks)
+	}
+
+	return tl, nil
+}
+
+// at returns the index of the track playing at t and how far into it
+// playback is.
+func (tl *timeline) at(t time.Time) (int, time.Duration) {
+	elapsed := t.Sub(tl.epoch) % tl.total
+	if elapsed < 0 {
+		elapsed += tl.total
+	}
+
+	for i, track := range tl.tracks {
+		if elapsed < track.length {
+			return i, elapsed
+		}
+		elapsed -= track.length
+	}
+
+	// Only reachable through rounding at the very end of the rotation.
+	return 0, 0
+}
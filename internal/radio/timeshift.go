@@ -0,0 +1,386 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Pertsaa/go-radio/internal/mp3"
+)
+
+const (
+	// shiftSegmentLength is how much audio each time-shift file holds. Whole
+	// files are deleted once they fall out of the window.
+	shiftSegmentLength = time.Minute
+	// maxShiftGap caps the pause between two chunks played back from the
+	// time-shift buffer, so listeners do not wait through gaps such as a
+	// restart of the broadcast loop.
+	maxShiftGap = time.Second
+)
+
+// timeShift keeps the last window of a channel's broadcast on disk so
+// listeners can start behind live.
+type timeShift struct {
+	dir    string
+	window time.Duration
+
+	mu       sync.Mutex
+	segments []*shiftSegment
+	tracks   []shiftTrack
+	seq      int64
+	failed   bool
+	// appended is closed and replaced whenever a chunk is added.
+	appended chan struct{}
+}
+
+type shiftSegment struct {
+	file    *os.File
+	path    string
+	size    int64
+	entries []shiftEntry
+}
+
+// shiftEntry locates one broadcast chunk in its segment.
+type shiftEntry struct {
+	seq    int64
+	at     time.Time
+	offset int64
+	size   int
+}
+
+type shiftTrack struct {
+	name string
+	at   time.Time
+}
+
+// newTimeShift prepares an empty buffer in dir, removing anything left over
+// from a previous run.
+func newTimeShift(dir string, window time.Duration) (*timeShift, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to clear time-shift directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create time-shift directory: %w", err)
+	}
+	return &timeShift{
+		dir:      dir,
+		window:   window,
+		appended: make(chan struct{}),
+	}, nil
+}
+
+// write appends a chunk and drops segments that have fallen out of the
+// window.
+func (ts *timeShift) write(chunk AudioChunk) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	seg, err := ts.segmentFor(chunk.Time)
+	if err != nil {
+		return err
+	}
+
+	n, err := seg.file.WriteAt(chunk.Data, seg.size)
+	if err != nil {
+		if len(seg.entries) == 0 {
+			seg.close()
+			ts.segments = ts.segments[:len(ts.segments)-1]
+		}
+		return err
+	}
+
+	ts.seq++
+	seg.entries = append(seg.entries, shiftEntry{seq: ts.seq, at: chunk.Time, offset: seg.size, size: n})
+	seg.size += int64(n)
+
+	ts.prune(chunk.Time)
+
+	close(ts.appended)
+	ts.appended = make(chan struct{})
+	return nil
+}
+
+// segmentFor returns the segment to write a chunk broadcast at t to, starting
+// a new one when the current one is full.
+func (ts *timeShift) segmentFor(t time.Time) (*shiftSegment, error) {
+	if n := len(ts.segments); n > 0 {
+		seg := ts.segments[n-1]
+		if len(seg.entries) == 0 || t.Sub(seg.entries[0].at) < shiftSegmentLength {
+			return seg, nil
+		}
+	}
+
+	path := filepath.Join(ts.dir, strconv.FormatInt(ts.seq+1, 10)+".mp3")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &shiftSegment{file: file, path: path}
+	ts.segments = append(ts.segments, seg)
+	return seg, nil
+}
+
+// prune must be called with mu held.
+func (ts *timeShift) prune(now time.Time) {
+	cutoff := now.Add(-ts.window)
+	for len(ts.segments) > 1 {
+		seg := ts.segments[0]
+		if seg.entries[len(seg.entries)-1].at.After(cutoff) {
+			break
+		}
+		seg.close()
+		ts.segments = ts.segments[1:]
+	}
+
+	// Keep the track that was playing at the start of the window.
+	i := sort.Search(len(ts.tracks), func(i int) bool {
+		return ts.tracks[i].at.After(cutoff)
+	})
+	if i > 1 {
+		ts.tracks = ts.tracks[i-1:]
+	}
+}
+
+func (seg *shiftSegment) close() {
+	seg.file.Close()
+	os.Remove(seg.path)
+}
+
+func (ts *timeShift) trackStarted(name string, at time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.tracks = append(ts.tracks, shiftTrack{name: name, at: at})
+}
+
+// trackStart returns when the track playing now started, or the start of
+// the buffer if that is later.
+func (ts *timeShift) trackStart() (time.Time, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.tracks) == 0 || len(ts.segments) == 0 {
+		return time.Time{}, false
+	}
+	start := ts.tracks[len(ts.tracks)-1].at
+	if oldest := ts.segments[0].entries[0].at; start.Before(oldest) {
+		start = oldest
+	}
+	return start, true
+}
+
+// seek returns the sequence number of the first chunk broadcast at or after
+// t, clamped to the oldest chunk still buffered.
+func (ts *timeShift) seek(t time.Time) int64 {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	for _, seg := range ts.segments {
+		last := seg.entries[len(seg.entries)-1]
+		if last.at.Before(t) {
+			continue
+		}
+		i := sort.Search(len(seg.entries), func(i int) bool {
+			return !seg.entries[i].at.Before(t)
+		})
+		return seg.entries[i].seq
+	}
+	return ts.seq + 1
+}
+
+// read returns the chunk with sequence number seq. When it is not written yet
+// ok is false and the returned channel is closed once more audio arrives. A
+// chunk that has already left the window is replaced by the oldest one left.
+func (ts *timeShift) read(seq int64) (chunk AudioChunk, next int64, ok bool, wait <-chan struct{}, err error) {
+	ts.mu.Lock()
+	if seq > ts.seq || len(ts.segments) == 0 {
+		wait = ts.appended
+		ts.mu.Unlock()
+		return AudioChunk{}, seq, false, wait, nil
+	}
+
+	i := sort.Search(len(ts.segments), func(i int) bool {
+		entries := ts.segments[i].entries
+		return entries[len(entries)-1].seq >= seq
+	})
+	seg := ts.segments[i]
+	entry := seg.entries[0]
+	if seq > entry.seq {
+		entry = seg.entries[seq-entry.seq]
+	}
+	ts.mu.Unlock()
+
+	data := make([]byte, entry.size)
+	if _, err := seg.file.ReadAt(data, entry.offset); err != nil {
+		if errors.Is(err, os.ErrClosed) {
+			// The segment was pruned while reading; skip ahead.
+			return ts.read(entry.seq + 1)
+		}
+		return AudioChunk{}, seq, false, nil, err
+	}
+	return AudioChunk{Data: data, Time: entry.at}, entry.seq + 1, true, nil, nil
+}
+
+func (ts *timeShift) close() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, seg := range ts.segments {
+		seg.close()
+	}
+	ts.segments = nil
+	os.Remove(ts.dir)
+}
+
+func (r *Radio) timeShiftDir(channel Channel) string {
+	return filepath.Join(r.dir, ".timeshift", channel.Name)
+}
+
+// shiftWrite adds a chunk to the channel's time-shift buffer, if it has one.
+// Failures are logged once until writing works again.
+func (r *Radio) shiftWrite(b *broadcaster, chunk AudioChunk) {
+	if b.shift == nil {
+		return
+	}
+	err := b.shift.write(chunk)
+
+	b.shift.mu.Lock()
+	defer b.shift.mu.Unlock()
+	switch {
+	case err != nil && !b.shift.failed:
+		r.logger(b.channel).Warn("failed to write time-shift buffer", "error", err)
+		b.shift.failed = true
+	case err == nil && b.shift.failed:
+		r.logger(b.channel).Info("time-shift buffer recovered")
+		b.shift.failed = false
+	}
+}
+
+// TimeShiftWindow returns how far behind live listeners of a channel can
+// start, which is zero when time shift is off.
+func (r *Radio) TimeShiftWindow(channelID string) (time.Duration, error) {
+	b, ok := r.getBroadcaster(channelID)
+	if !ok {
+		return 0, ErrChannelNotFound
+	}
+	if b.shift == nil {
+		return 0, nil
+	}
+	return b.shift.window, nil
+}
+
+// TrackStart returns when the track playing on a channel started, as far back
+// as its time-shift buffer reaches.
+func (r *Radio) TrackStart(channelID string) (time.Time, error) {
+	b, ok := r.getBroadcaster(channelID)
+	if !ok {
+		return time.Time{}, ErrChannelNotFound
+	}
+	if b.shift == nil {
+		return time.Time{}, ErrTimeShiftDisabled
+	}
+	start, ok := b.shift.trackStart()
+	if !ok {
+		return time.Time{}, ErrChannelOffline
+	}
+	return start, nil
+}
+
+// StreamTimeShift writes the channel's broadcast to w starting from what was
+// played at the given time, then keeps the same distance behind live until
+// the session ends. Times before the buffer start at its oldest audio.
+func (r *Radio) StreamTimeShift(ctx context.Context, w http.ResponseWriter, l *Listener, at time.Time) error {
+	b, ok := r.getBroadcaster(l.ChannelID)
+	if !ok {
+		return ErrChannelNotFound
+	}
+	if b.shift == nil {
+		return ErrTimeShiftDisabled
+	}
+
+	// Live chunks are not queued for this listener.
+	l.shifted.Store(true)
+
+	go func() {
+		select {
+		case <-l.closed:
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now())
+		case <-ctx.Done():
+		}
+	}()
+
+	seq := b.shift.seek(at)
+	var due, last time.Time
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		chunk, next, ok, wait, err := b.shift.read(seq)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Caught up with live.
+			select {
+			case <-ctx.Done():
+				l.end(r.endReason())
+				return nil
+			case <-r.done:
+				l.end(ReasonServerShutdown)
+				return nil
+			case <-l.closed:
+				return nil
+			case <-wait:
+			}
+			continue
+		}
+
+		if due.IsZero() {
+			due = time.Now()
+			w.Header().Set("X-Time-Shift", strconv.FormatFloat(due.Sub(chunk.Time).Seconds(), 'f', 0, 64))
+			// Start on a frame boundary.
+			if i := mp3.Sync(chunk.Data); i >= 0 {
+				chunk.Data = chunk.Data[i:]
+			}
+		} else {
+			due = due.Add(min(max(chunk.Time.Sub(last), 0), maxShiftGap))
+		}
+		last = chunk.Time
+		seq = next
+
+		timer.Reset(time.Until(due))
+		select {
+		case <-ctx.Done():
+			l.end(r.endReason())
+			return nil
+		case <-r.done:
+			l.end(ReasonServerShutdown)
+			return nil
+		case <-l.closed:
+			return nil
+		case <-timer.C:
+		}
+
+		if err := r.write(w, l, chunk.Data, b.settings.WriteTimeout); err != nil {
+			return err
+		}
+	}
+}
+
+// openTimeShift sets up the channel's time-shift buffer when it has a window.
+func (r *Radio) openTimeShift(b *broadcaster) {
+	if b.settings.TimeShift <= 0 {
+		return
+	}
+	ts, err := newTimeShift(r.timeShiftDir(b.channel), b.settings.TimeShift)
+	if err != nil {
+		slog.Warn("time shift disabled", "channel", b.channel.Name, "error", err)
+		return
+	}
+	b.shift = ts
+}
@@ -0,0 +1,149 @@
+package radio
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// shiftInterval is the gap between the chunks written by fillTimeShift.
+const shiftInterval = 30 * time.Second
+
+// fillTimeShift returns a buffer with a three-minute window and the ten
+// chunks written to it, 30 seconds apart and ending now. Each chunk holds its
+// sequence number, so chunks[i] has sequence number i+1. The first four have
+// fallen out of the window.
+func fillTimeShift(t *testing.T) (*timeShift, []AudioChunk) {
+	t.Helper()
+	ts, err := newTimeShift(filepath.Join(t.TempDir(), "shift"), 3*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ts.close)
+
+	start := time.Now().Add(-9 * shiftInterval)
+	var chunks []AudioChunk
+	for i := range 10 {
+		chunk := AudioChunk{Data: []byte(strconv.Itoa(i + 1)), Time: start.Add(time.Duration(i) * shiftInterval)}
+		if err := ts.write(chunk); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return ts, chunks
+}
+
+func TestTimeShiftPrune(t *testing.T) {
+	ts, _ := fillTimeShift(t)
+	if len(ts.segments) != 3 {
+		t.Fatalf("%d segments, want 3", len(ts.segments))
+	}
+	if first := ts.segments[0].entries[0].seq; first != 5 {
+		t.Errorf("oldest chunk = %d, want 5", first)
+	}
+}
+
+func TestTimeShiftSeek(t *testing.T) {
+	ts, chunks := fillTimeShift(t)
+	newest := chunks[len(chunks)-1].Time
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want int64
+	}{
+		{name: "before the window", at: chunks[0].Time, want: 5},
+		{name: "oldest chunk", at: chunks[4].Time, want: 5},
+		{name: "exact chunk", at: chunks[5].Time, want: 6},
+		{name: "between chunks", at: chunks[5].Time.Add(time.Second), want: 7},
+		{name: "segment boundary", at: chunks[6].Time, want: 7},
+		{name: "newest chunk", at: newest, want: 10},
+		{name: "after the newest chunk", at: newest.Add(time.Second), want: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ts.seek(tt.at); got != tt.want {
+				t.Errorf("seek() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeShiftRead(t *testing.T) {
+	ts, chunks := fillTimeShift(t)
+
+	tests := []struct {
+		name string
+		seq  int64
+		want int64
+	}{
+		{name: "oldest chunk", seq: 5, want: 5},
+		{name: "within a segment", seq: 6, want: 6},
+		{name: "next segment", seq: 7, want: 7},
+		{name: "newest chunk", seq: 10, want: 10},
+		{name: "pruned chunk", seq: 2, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk, next, ok, _, err := ts.read(tt.seq)
+			if err != nil || !ok {
+				t.Fatalf("read() = ok %v, error %v", ok, err)
+			}
+			want := chunks[tt.want-1]
+			if string(chunk.Data) != string(want.Data) || !chunk.Time.Equal(want.Time) {
+				t.Errorf("read() = %q at %v, want chunk %d", chunk.Data, chunk.Time, tt.want)
+			}
+			if next != tt.want+1 {
+				t.Errorf("next = %d, want %d", next, tt.want+1)
+			}
+		})
+	}
+}
+
+func TestTimeShiftReadWaits(t *testing.T) {
+	ts, chunks := fillTimeShift(t)
+
+	_, next, ok, wait, err := ts.read(11)
+	if err != nil || ok || next != 11 {
+		t.Fatalf("read() = next %d, ok %v, error %v, want to wait at 11", next, ok, err)
+	}
+	select {
+	case <-wait:
+		t.Fatal("wait closed before a write")
+	default:
+	}
+
+	live := AudioChunk{Data: []byte("11"), Time: chunks[len(chunks)-1].Time.Add(shiftInterval)}
+	if err := ts.write(live); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-wait:
+	default:
+		t.Fatal("wait not closed by a write")
+	}
+
+	chunk, _, ok, _, err := ts.read(11)
+	if err != nil || !ok || string(chunk.Data) != "11" {
+		t.Errorf("read() = %q, ok %v, error %v, want chunk 11", chunk.Data, ok, err)
+	}
+}
+
+func TestTimeShiftTrackStart(t *testing.T) {
+	ts, chunks := fillTimeShift(t)
+
+	if _, ok := ts.trackStart(); ok {
+		t.Error("trackStart() ok before any track started")
+	}
+
+	ts.trackStarted("old", chunks[0].Time)
+	if start, ok := ts.trackStart(); !ok || !start.Equal(chunks[4].Time) {
+		t.Errorf("trackStart() = %v, %v, want the oldest chunk", start, ok)
+	}
+
+	ts.trackStarted("new", chunks[7].Time)
+	if start, ok := ts.trackStart(); !ok || !start.Equal(chunks[7].Time) {
+		t.Errorf("trackStart() = %v, %v, want the start of the track", start, ok)
+	}
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var historyBucket = []byte("history")
+
+// PlayKind tells how a history entry came to be played. Only tracks from the
+// channel's rotation are played for now; requests and jingles would get kinds
+// of their own once the radio can play them.
+type PlayKind string
+
+const PlayKindTrack PlayKind = "track"
+
+// Play is a single entry in a channel's play history.
+type Play struct {
+	Track     string    `json:"track"`
+	Kind      PlayKind  `json:"kind"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	Skipped   bool      `json:"skipped"`
+	Listeners int       `json:"listeners"`
+	// Part, Title and Artist identify a track inside Track's cue sheet.
+	Part   int    `json:"part,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+}
+
+// Plays are keyed by start time so they are stored in play order. Each
+// channel has its own nested bucket, named by channel ID.
+func playKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+}
+
+// AddPlay records a finished play on a channel.
+func (s *Store) AddPlay(channelID string, play Play) error {
+	value, err := json.Marshal(play)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(channelID))
+		if err != nil {
+			return err
+		}
+		return b.Put(playKey(play.StartedAt), value)
+	})
+}
+
+// History returns up to limit plays of a channel that started before the
+// given time, newest first. A zero before means now.
+func (s *Store) History(channelID string, limit int, before time.Time) ([]Play, error) {
+	plays := []Play{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket).Bucket([]byte(channelID))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		var k, v []byte
+		if before.IsZero() {
+			k, v = c.Last()
+		} else {
+			end := playKey(before)
+			k, v = c.Seek(end)
+			if k == nil {
+				k, v = c.Last()
+			}
+			if k != nil && bytes.Compare(k, end) >= 0 {
+				k, v = c.Prev()
+			}
+		}
+
+		for ; k != nil && len(plays) < limit; k, v = c.Prev() {
+			var play Play
+			if err := json.Unmarshal(v, &play); err != nil {
+				return err
+			}
+			plays = append(plays, play)
+		}
+		return nil
+	})
+
+	return plays, err
+}
+
+// PruneHistory deletes plays that started before the given time and returns
+// how many were removed.
+func (s *Store) PruneHistory(before time.Time) (int, error) {
+	end := playKey(before)
+	pruned := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(historyBucket).ForEachBucket(func(name []byte) error {
+			b := tx.Bucket(historyBucket).Bucket(name)
+
+			var keys [][]byte
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.Next() {
+				keys = append(keys, bytes.Clone(k))
+			}
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			pruned += len(keys)
+			return nil
+		})
+	})
+
+	return pruned, err
+}
+
+// RunHistoryRetention prunes plays older than retention once an hour until
+// ctx is done. A zero retention keeps history forever.
+func (s *Store) RunHistoryRetention(ctx context.Context, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := s.PruneHistory(time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("failed to prune play history", "error", err)
+		} else if pruned > 0 {
+			slog.Info("pruned play history", "plays", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// addPlays records back-to-back one minute plays of the given tracks on a
+// channel, starting at start, and returns them in play order.
+func addPlays(t *testing.T, s *Store, channelID string, start time.Time, tracks ...string) []Play {
+	t.Helper()
+	var plays []Play
+	for i, track := range tracks {
+		play := Play{
+			Track:     track,
+			Kind:      PlayKindTrack,
+			StartedAt: start.Add(time.Duration(i) * time.Minute),
+			EndedAt:   start.Add(time.Duration(i+1) * time.Minute),
+		}
+		if err := s.AddPlay(channelID, play); err != nil {
+			t.Fatal(err)
+		}
+		plays = append(plays, play)
+	}
+	return plays
+}
+
+func trackNames(plays []Play) []string {
+	var names []string
+	for _, p := range plays {
+		names = append(names, p.Track)
+	}
+	return names
+}
+
+func TestHistory(t *testing.T) {
+	s := openTestStore(t)
+	start := time.Now().Add(-time.Hour)
+	a := addPlays(t, s, "a", start, "one", "two", "three", "four", "five")
+	addPlays(t, s, "b", start, "jazz")
+
+	tests := []struct {
+		name    string
+		channel string
+		limit   int
+		before  time.Time
+		want    []string
+	}{
+		{name: "latest", channel: "a", limit: 10, want: []string{"five", "four", "three", "two", "one"}},
+		{name: "limited", channel: "a", limit: 2, want: []string{"five", "four"}},
+		{name: "before a play", channel: "a", limit: 10, before: a[2].StartedAt, want: []string{"two", "one"}},
+		{name: "just after a play", channel: "a", limit: 10, before: a[2].StartedAt.Add(time.Nanosecond), want: []string{"three", "two", "one"}},
+		{name: "after every play", channel: "a", limit: 10, before: a[4].EndedAt, want: []string{"five", "four", "three", "two", "one"}},
+		{name: "before every play", channel: "a", limit: 10, before: a[0].StartedAt, want: nil},
+		{name: "zero limit", channel: "a", limit: 0, want: nil},
+		{name: "other channel", channel: "b", limit: 10, want: []string{"jazz"}},
+		{name: "unknown channel", channel: "c", limit: 10, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plays, err := s.History(tt.channel, tt.limit, tt.before)
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+			if plays == nil {
+				t.Error("History() = nil, want an empty list")
+			}
+			if got := trackNames(plays); !slices.Equal(got, tt.want) {
+				t.Errorf("History() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryPaging(t *testing.T) {
+	s := openTestStore(t)
+	addPlays(t, s, "a", time.Now().Add(-time.Hour), "one", "two", "three", "four", "five")
+
+	var pages [][]string
+	var before time.Time
+	for {
+		plays, err := s.History("a", 2, before)
+		if err != nil {
+			t.Fatalf("History() error = %v", err)
+		}
+		if len(plays) == 0 {
+			break
+		}
+		pages = append(pages, trackNames(plays))
+		before = plays[len(plays)-1].StartedAt
+	}
+
+	want := [][]string{{"five", "four"}, {"three", "two"}, {"one"}}
+	if !slices.EqualFunc(pages, want, slices.Equal) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now()
+	addPlays(t, s, "a", now.Add(-3*time.Hour), "old", "older")
+	addPlays(t, s, "a", now.Add(-time.Hour), "recent")
+	addPlays(t, s, "b", now.Add(-3*time.Hour), "old")
+
+	pruned, err := s.PruneHistory(now.Add(-2 * time.Hour))
+	if err != nil {
+		t.Fatalf("PruneHistory() error = %v", err)
+	}
+	if pruned != 3 {
+		t.Errorf("pruned %d plays, want 3", pruned)
+	}
+
+	for channel, want := range map[string][]string{"a": {"recent"}, "b": nil} {
+		plays, err := s.History(channel, 10, time.Time{})
+		if err != nil {
+			t.Fatalf("History() error = %v", err)
+		}
+		if got := trackNames(plays); !slices.Equal(got, want) {
+			t.Errorf("channel %s history = %v, want %v", channel, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var libraryBucket = []byte("library")
+
+// Track is an indexed audio file, keyed by its path relative to the data
+// directory.
+type Track struct {
+	Path      string    `json:"path"`
+	Channel   string    `json:"channel"`
+	ChannelID string    `json:"channelId"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	// Hash is the SHA-256 of the file's content, truncated to 128 bits.
+	Hash string `json:"hash"`
+	// Duration is the exact length in seconds, counted from the MP3 frames.
+	Duration float64 `json:"duration"`
+	// Bitrate is the average bitrate in kbit/s.
+	Bitrate    int    `json:"bitrate"`
+	SampleRate int    `json:"sampleRate"`
+	Title      string `json:"title,omitempty"`
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+	Genre      string `json:"genre,omitempty"`
+	Year       string `json:"year,omitempty"`
+	Number     string `json:"number,omitempty"`
+	// Error is set when the file could not be read as audio.
+	Error     string    `json:"error,omitempty"`
+	IndexedAt time.Time `json:"indexedAt"`
+	// Part and Start are set on the tracks of a cue sheet, which share the
+	// path of the file they are in. Start is in seconds into the file.
+	Part  int     `json:"part,omitempty"`
+	Start float64 `json:"start,omitempty"`
+	// Parts are the tracks listed in the file's cue sheet, which was last
+	// modified at CueModTime.
+	Parts      []Track   `json:"parts,omitempty"`
+	CueModTime time.Time `json:"cueModTime,omitzero"`
+}
+
+// Tracks returns every indexed track.
+func (s *Store) Tracks() ([]Track, error) {
+	tracks := []Track{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(libraryBucket).ForEach(func(_, v []byte) error {
+			var t Track
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			tracks = append(tracks, t)
+			return nil
+		})
+	})
+	return tracks, err
+}
+
+// UpdateTracks adds or replaces the given tracks and removes the tracks at
+// the deleted paths in a single transaction.
+func (s *Store) UpdateTracks(put []Track, deleted []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(libraryBucket)
+		for _, t := range put {
+			value, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(t.Path), value); err != nil {
+				return err
+			}
+		}
+		for _, path := range deleted {
+			if err := b.Delete([]byte(path)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
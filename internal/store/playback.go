@@ -0,0 +1,45 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var playbackBucket = []byte("playback")
+
+// Playback is where a channel was in its rotation when last saved.
+type Playback struct {
+	Track   string    `json:"track"`
+	Index   int       `json:"index"`
+	Offset  int64     `json:"offset"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+func (s *Store) SavePlayback(channelID string, p Playback) error {
+	value, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(playbackBucket).Put([]byte(channelID), value)
+	})
+}
+
+// Playback returns the saved playback position of a channel. ok is false if
+// none was saved.
+func (s *Store) Playback(channelID string) (p Playback, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(playbackBucket).Get([]byte(channelID))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(v, &p)
+	})
+	return p, ok, err
+}